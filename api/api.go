@@ -6,22 +6,30 @@ import (
 
 	"hermannm.dev/analysis/config"
 	"hermannm.dev/analysis/db"
+	"hermannm.dev/analysis/jobs"
 )
 
 type AnalysisAPI struct {
 	db     db.AnalysisDB
+	jobs   *jobs.Manager
 	router *http.ServeMux
 	config config.API
 }
 
 func NewAnalysisAPI(db db.AnalysisDB, router *http.ServeMux, config config.Config) AnalysisAPI {
-	api := AnalysisAPI{db: db, router: router, config: config.API}
+	api := AnalysisAPI{db: db, jobs: jobs.NewManager(), router: router, config: config.API}
 
 	api.router.HandleFunc("/run-query", api.RunAnalysisQuery)
+	api.router.HandleFunc("/table-rows", api.QueryTableRows)
+	api.router.HandleFunc("/distinct-values", api.GetDistinctColumnValues)
+	api.router.HandleFunc("/profile-table", api.ProfileTable)
 	api.router.HandleFunc("/create-table-from-csv", api.CreateTableFromCSV)
 	api.router.HandleFunc("/ingest-data-from-csv", api.IngestDataFromCSV)
 	api.router.HandleFunc("/get-table-schema", api.GetTableSchema)
 	api.router.HandleFunc("/deduce-csv-table-schema", api.DeduceCSVTableSchema)
+	api.router.HandleFunc("/ingestion-jobs", api.ListIngestionJobs)
+	api.router.HandleFunc("/ingestion-job", api.GetIngestionJob)
+	api.router.HandleFunc("/cancel-ingestion-job", api.CancelIngestionJob)
 
 	return api
 }
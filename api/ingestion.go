@@ -1,91 +1,283 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 
 	"hermannm.dev/analysis/csv"
 	"hermannm.dev/analysis/db"
+	"hermannm.dev/analysis/jobs"
+	"hermannm.dev/devlog/log"
 	"hermannm.dev/wrap"
 )
 
 // Expects:
 //   - multipart form field 'tableSchema': JSON-encoded db.TableSchema
 //   - multipart form field 'csvFile': CSV file to read data from
+//   - multipart form field 'encoding' (optional): encoding of the CSV file (see csv.Encoding),
+//     detected from the file if omitted
+//   - multipart form field 'headerOptions' (optional): JSON-encoded csv.HeaderOptions, where only
+//     hasHeaderRow is used (defaults to true, unlike for schema deduction, so clients should send
+//     the hasHeaderRow returned by DeduceCSVTableSchema)
+//   - multipart form field 'ingestionOptions' (optional): JSON-encoded db.IngestionOptions, for
+//     skipping rows that fail to convert instead of aborting
+//   - multipart form field 'reportFormat' (optional): "JSON" (default) or "CSV", where CSV returns
+//     only the rejected rows, as a file download
+//   - multipart form field 'async' (optional): if "true", ingestion runs in the background
+//
+// Alternatively, the CSV file can be sent as the raw request body with Content-Type text/csv, and
+// the other fields as query parameters. The body is then streamed into the database as it is
+// received, instead of being buffered first like multipart uploads, so large files can be
+// ingested with bounded memory.
+//
+// The CSV file may be compressed with gzip, zstd or zip (see getCSVFileFromRequest).
+//
+// Returns:
+//   - db.IngestionReport in the requested format, or if async, JSON-encoded jobs.Job for tracking
+//     the ingestion (see GetIngestionJob)
 func (api AnalysisAPI) CreateTableFromCSV(res http.ResponseWriter, req *http.Request) {
-	schema, err := getTableSchemaFromRequest(req)
+	request, err := getIngestionRequest(req)
 	if err != nil {
 		sendClientError(res, err, "")
 		return
 	}
 
-	csvFile, _, err := req.FormFile("csvFile")
+	csvFile, err := getCSVFileFromRequest(req)
 	if err != nil {
-		sendClientError(res, err, "failed to get CSV file from request")
+		sendClientError(res, err, "")
 		return
 	}
 	defer csvFile.Close()
 
-	if err := api.db.CreateTable(req.Context(), schema); err != nil {
-		sendServerError(res, err, "failed to create table from uploaded CSV")
+	api.ingestCSV(res, req, csvFile, request, api.createTableAndIngestData)
+}
+
+// Expects:
+//   - multipart form field 'tableSchema': JSON-encoded db.TableSchema
+//   - multipart form field 'csvFile': CSV file to read data from
+//   - multipart form field 'encoding' (optional): encoding of the CSV file (see csv.Encoding),
+//     detected from the file if omitted
+//   - multipart form field 'headerOptions' (optional): JSON-encoded csv.HeaderOptions, where only
+//     hasHeaderRow is used (defaults to true, unlike for schema deduction, so clients should send
+//     the hasHeaderRow returned by DeduceCSVTableSchema)
+//   - multipart form field 'ingestionOptions' (optional): JSON-encoded db.IngestionOptions, for
+//     skipping rows that fail to convert instead of aborting
+//   - multipart form field 'reportFormat' (optional): "JSON" (default) or "CSV", where CSV returns
+//     only the rejected rows, as a file download
+//   - multipart form field 'async' (optional): if "true", ingestion runs in the background
+//
+// Alternatively, the CSV file can be sent as the raw request body with Content-Type text/csv, and
+// the other fields as query parameters. The body is then streamed into the database as it is
+// received, instead of being buffered first like multipart uploads, so large files can be
+// ingested with bounded memory.
+//
+// The CSV file may be compressed with gzip, zstd or zip (see getCSVFileFromRequest).
+//
+// Returns:
+//   - db.IngestionReport in the requested format, or if async, JSON-encoded jobs.Job for tracking
+//     the ingestion (see GetIngestionJob)
+func (api AnalysisAPI) IngestDataFromCSV(res http.ResponseWriter, req *http.Request) {
+	request, err := getIngestionRequest(req)
+	if err != nil {
+		sendClientError(res, err, "")
 		return
 	}
 
-	if err := api.db.StoreTableSchema(req.Context(), schema); err != nil {
-		_, dropErr := api.db.DropTable(req.Context(), schema.TableName)
-		if dropErr == nil {
-			sendServerError(res, err, "failed to store table schema")
-			return
+	csvFile, err := getCSVFileFromRequest(req)
+	if err != nil {
+		sendClientError(res, err, "")
+		return
+	}
+	defer csvFile.Close()
+
+	api.ingestCSV(res, req, csvFile, request, api.db.IngestData)
+}
+
+// Options for CreateTableFromCSV and IngestDataFromCSV, parsed before any side effects so that
+// invalid requests fail early.
+type ingestionRequest struct {
+	schema           db.TableSchema
+	headerOptions    csv.HeaderOptions
+	encoding         csv.Encoding
+	ingestionOptions db.IngestionOptions
+	reportFormat     string
+	async            bool
+}
+
+func getIngestionRequest(req *http.Request) (request ingestionRequest, err error) {
+	if request.schema, err = getTableSchemaFromRequest(req); err != nil {
+		return ingestionRequest{}, err
+	}
+	if request.headerOptions, err = getHeaderOptionsFromRequest(req); err != nil {
+		return ingestionRequest{}, err
+	}
+	if request.encoding, err = getEncodingFromRequest(req); err != nil {
+		return ingestionRequest{}, err
+	}
+	if request.ingestionOptions, err = getIngestionOptionsFromRequest(req); err != nil {
+		return ingestionRequest{}, err
+	}
+	if request.reportFormat, err = getReportFormatFromRequest(req); err != nil {
+		return ingestionRequest{}, err
+	}
+
+	if asyncInput := req.FormValue("async"); asyncInput != "" {
+		if request.async, err = strconv.ParseBool(asyncInput); err != nil {
+			return ingestionRequest{}, wrap.Error(err, "invalid 'async' field in request")
+		}
+	}
+
+	return request, nil
+}
+
+// Matches db.AnalysisDB.IngestData.
+type ingestFunc func(
+	ctx context.Context,
+	data db.DataSource,
+	schema db.TableSchema,
+	options db.IngestionOptions,
+) (db.IngestionReport, error)
+
+// Reads the given CSV file and ingests it with the given function, either while the request waits,
+// or in a background job if the request is async. If the file can't seek, it is streamed.
+func (api AnalysisAPI) ingestCSV(
+	res http.ResponseWriter,
+	req *http.Request,
+	csvFile io.Reader,
+	request ingestionRequest,
+	ingest ingestFunc,
+) {
+	skipHeaderRow := request.headerOptions.SkipHeaderRowOnIngestion()
+
+	if !request.async {
+		var csvReader *csv.Reader
+		var err error
+		if seekableFile, ok := csvFile.(io.ReadSeeker); ok {
+			csvReader, err = csv.NewReader(seekableFile, skipHeaderRow, request.encoding)
 		} else {
-			sendServerError(res, wrap.Errors(
-				"failed to store table schema AND failed to clean up invalid created table afterwards",
-				err,
-				dropErr,
-			), "")
+			csvReader, err = csv.NewStreamReader(csvFile, skipHeaderRow, request.encoding)
+		}
+		if err != nil {
+			sendServerError(res, err, "failed to read uploaded CSV file")
 			return
 		}
+
+		report, err := ingest(req.Context(), csvReader, request.schema, request.ingestionOptions)
+		if err != nil {
+			sendServerError(res, err, "failed to insert data from uploaded CSV")
+			return
+		}
+
+		sendIngestionReport(res, report, request.reportFormat)
+		return
 	}
 
-	csvReader, err := csv.NewReader(csvFile, true)
+	// The uploaded file is removed (or for streams, closed) when the request finishes, so the job
+	// needs its own copy
+	jobFile, fileSize, err := copyToTempFile(csvFile)
 	if err != nil {
-		sendServerError(res, err, "failed to read uploaded CSV file")
+		sendServerError(res, err, "failed to store uploaded CSV file for ingestion job")
 		return
 	}
 
-	if err := api.db.IngestData(req.Context(), csvReader, schema); err != nil {
-		sendServerError(res, err, "failed to insert CSV data after creating table")
+	tracker := jobs.NewTracker(fileSize)
+	csvReader, err := csv.NewReader(tracker.CountBytes(jobFile), skipHeaderRow, request.encoding)
+	if err != nil {
+		removeTempFile(jobFile)
+		sendServerError(res, err, "failed to read uploaded CSV file")
 		return
 	}
+
+	request.ingestionOptions.Progress = tracker.IngestionProgress()
+
+	job := api.jobs.Start(
+		req.Context(),
+		request.schema.TableName,
+		tracker,
+		func(ctx context.Context) (db.IngestionReport, error) {
+			defer removeTempFile(jobFile)
+
+			report, err := ingest(ctx, csvReader, request.schema, request.ingestionOptions)
+			if err != nil {
+				return report, wrap.Error(err, "failed to insert data from uploaded CSV")
+			}
+			return report, nil
+		},
+	)
+
+	sendJSON(res, job)
 }
 
-// Expects:
-//   - multipart form field 'tableSchema': JSON-encoded db.TableSchema
-//   - multipart form field 'csvFile': CSV file to read data from
-func (api AnalysisAPI) IngestDataFromCSV(res http.ResponseWriter, req *http.Request) {
-	schema, err := getTableSchemaFromRequest(req)
-	if err != nil {
-		sendClientError(res, err, "")
-		return
+// Creates the table and stores its schema before ingesting data into it. If any step fails, the
+// table and stored schema are cleaned up, so that the client can retry with the same table name.
+func (api AnalysisAPI) createTableAndIngestData(
+	ctx context.Context,
+	data db.DataSource,
+	schema db.TableSchema,
+	options db.IngestionOptions,
+) (db.IngestionReport, error) {
+	if err := api.db.CreateTable(ctx, schema); err != nil {
+		return db.IngestionReport{}, wrap.Error(err, "failed to create table")
 	}
 
-	csvFile, _, err := req.FormFile("csvFile")
-	if err != nil {
-		sendClientError(res, err, "failed to get file upload from request")
-		return
+	if err := api.db.StoreTableSchema(ctx, schema); err != nil {
+		return db.IngestionReport{}, api.cleanUpCreatedTable(
+			ctx,
+			schema.TableName,
+			false,
+			wrap.Error(err, "failed to store table schema"),
+		)
 	}
-	defer csvFile.Close()
 
-	csvReader, err := csv.NewReader(csvFile, true)
+	report, err := api.db.IngestData(ctx, data, schema, options)
 	if err != nil {
-		sendServerError(res, nil, "failed to read uploaded CSV file")
-		return
+		return report, api.cleanUpCreatedTable(
+			ctx,
+			schema.TableName,
+			true,
+			wrap.Error(err, "failed to insert data after creating table"),
+		)
 	}
 
-	if err := api.db.IngestData(req.Context(), csvReader, schema); err != nil {
-		sendServerError(res, err, "failed to insert data from uploaded CSV")
-		return
+	return report, nil
+}
+
+// Drops the given table and its stored schema after a failure in createTableAndIngestData.
+// Returns the error that caused the failure, combined with any errors from cleaning up.
+func (api AnalysisAPI) cleanUpCreatedTable(
+	ctx context.Context,
+	table string,
+	schemaStored bool,
+	err error,
+) error {
+	// Cleans up even if the request was canceled
+	ctx = context.WithoutCancel(ctx)
+
+	var cleanupErrs []error
+	if _, dropErr := api.db.DropTable(ctx, table); dropErr != nil {
+		cleanupErrs = append(cleanupErrs, wrap.Error(dropErr, "failed to drop table"))
 	}
+	if schemaStored {
+		if deleteErr := api.db.DeleteTableSchema(ctx, table); deleteErr != nil {
+			cleanupErrs = append(
+				cleanupErrs,
+				wrap.Error(deleteErr, "failed to delete table schema"),
+			)
+		}
+	}
+
+	if len(cleanupErrs) == 0 {
+		return err
+	}
+	return wrap.Errors(
+		"table creation failed AND failed to clean up created table afterwards",
+		append([]error{err}, cleanupErrs...)...,
+	)
 }
 
 func getTableSchemaFromRequest(req *http.Request) (db.TableSchema, error) {
@@ -104,3 +296,74 @@ func getTableSchemaFromRequest(req *http.Request) (db.TableSchema, error) {
 
 	return schema, nil
 }
+
+func getEncodingFromRequest(req *http.Request) (csv.Encoding, error) {
+	encodingInput := req.FormValue("encoding")
+	if encodingInput == "" {
+		return 0, nil
+	}
+
+	encoding, err := csv.ParseEncoding(encodingInput)
+	if err != nil {
+		return 0, wrap.Error(err, "invalid 'encoding' field in request")
+	}
+	return encoding, nil
+}
+
+func getIngestionOptionsFromRequest(req *http.Request) (db.IngestionOptions, error) {
+	var ingestionOptions db.IngestionOptions
+
+	ingestionOptionsInput := req.FormValue("ingestionOptions")
+	if ingestionOptionsInput == "" {
+		return ingestionOptions, nil
+	}
+	if err := json.Unmarshal([]byte(ingestionOptionsInput), &ingestionOptions); err != nil {
+		return db.IngestionOptions{}, wrap.Error(
+			err,
+			"failed to parse 'ingestionOptions' field in request",
+		)
+	}
+	if err := ingestionOptions.Validate(); err != nil {
+		return db.IngestionOptions{}, wrap.Error(err, "invalid ingestion options")
+	}
+
+	return ingestionOptions, nil
+}
+
+const (
+	reportFormatJSON = "JSON"
+	reportFormatCSV  = "CSV"
+)
+
+func getReportFormatFromRequest(req *http.Request) (string, error) {
+	switch reportFormat := req.FormValue("reportFormat"); reportFormat {
+	case "":
+		return reportFormatJSON, nil
+	case reportFormatJSON, reportFormatCSV:
+		return reportFormat, nil
+	default:
+		return "", fmt.Errorf(
+			"invalid 'reportFormat' field in request (expected %s or %s)",
+			reportFormatJSON,
+			reportFormatCSV,
+		)
+	}
+}
+
+func sendIngestionReport(res http.ResponseWriter, report db.IngestionReport, reportFormat string) {
+	if reportFormat != reportFormatCSV {
+		if report.RejectedRows == nil {
+			report.RejectedRows = []db.RejectedRow{}
+		}
+		sendJSON(res, report)
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/csv")
+	res.Header().Set("Content-Disposition", `attachment; filename="rejected-rows.csv"`)
+
+	// Headers have been sent once we start writing, so we can only log errors here
+	if err := csv.WriteRejectedRows(res, report.RejectedRows); err != nil {
+		log.Error(wrap.Error(err, "failed to write rejected rows to response"))
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import "net/http"
+
+// Returns:
+//   - JSON-encoded list of jobs.Job for ingestion jobs started with the 'async' field, most
+//     recently started first
+func (api AnalysisAPI) ListIngestionJobs(res http.ResponseWriter, req *http.Request) {
+	sendJSON(res, api.jobs.List())
+}
+
+// Expects:
+//   - query parameter 'id': ID of the ingestion job to get
+//
+// Returns:
+//   - JSON-encoded jobs.Job
+func (api AnalysisAPI) GetIngestionJob(res http.ResponseWriter, req *http.Request) {
+	id := req.URL.Query().Get("id")
+	if id == "" {
+		sendClientError(res, nil, "missing 'id' query parameter in request")
+		return
+	}
+
+	job, ok := api.jobs.Get(id)
+	if !ok {
+		sendError(res, nil, "no ingestion job found with the given ID", http.StatusNotFound)
+		return
+	}
+
+	sendJSON(res, job)
+}
+
+// Expects:
+//   - query parameter 'id': ID of the ingestion job to cancel
+//
+// Cancellation is asynchronous: the job's status is set to CANCELED once its ingestion has stopped
+// and been rolled back.
+func (api AnalysisAPI) CancelIngestionJob(res http.ResponseWriter, req *http.Request) {
+	id := req.URL.Query().Get("id")
+	if id == "" {
+		sendClientError(res, nil, "missing 'id' query parameter in request")
+		return
+	}
+
+	if err := api.jobs.Cancel(id); err != nil {
+		sendClientError(res, err, "failed to cancel ingestion job")
+		return
+	}
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+)
+
+// Expects:
+//   - query parameter 'table': name of table to profile
+//
+// Returns:
+//   - JSON-encoded db.TableProfile
+func (api AnalysisAPI) ProfileTable(res http.ResponseWriter, req *http.Request) {
+	table := req.URL.Query().Get("table")
+	if table == "" {
+		sendClientError(res, nil, "missing 'table' query parameter in request")
+		return
+	}
+
+	schema, err := api.db.GetTableSchema(req.Context(), table)
+	if err != nil {
+		sendServerError(res, err, "failed to get table schema")
+		return
+	}
+
+	profile, err := api.db.ProfileTable(req.Context(), schema)
+	if err != nil {
+		sendServerError(res, err, "failed to profile table")
+		return
+	}
+
+	sendJSON(res, profile)
+}
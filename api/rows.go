@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"hermannm.dev/analysis/db"
+)
+
+// Expects:
+//   - query parameter 'table': name of table to fetch rows from
+//   - body: JSON-encoded db.RowsQuery
+//
+// Returns:
+//   - JSON-encoded db.RowsResult
+func (api AnalysisAPI) QueryTableRows(res http.ResponseWriter, req *http.Request) {
+	table := req.URL.Query().Get("table")
+	if table == "" {
+		sendClientError(res, nil, "missing 'table' query parameter in request")
+		return
+	}
+
+	var rowsQuery db.RowsQuery
+	if err := json.NewDecoder(req.Body).Decode(&rowsQuery); err != nil {
+		sendClientError(res, err, "failed to parse rows query from request body")
+		return
+	}
+
+	schema, err := api.db.GetTableSchema(req.Context(), table)
+	if err != nil {
+		sendServerError(res, err, "failed to get table schema")
+		return
+	}
+
+	if _, err := rowsQuery.Validate(schema); err != nil {
+		sendClientError(res, err, "invalid rows query")
+		return
+	}
+
+	rowsResult, err := api.db.QueryRows(req.Context(), rowsQuery, schema)
+	if err != nil {
+		if errors.As(err, &db.InvalidCursorError{}) {
+			sendClientError(res, err, "invalid rows query cursor")
+		} else {
+			sendServerError(res, err, "failed to query table rows")
+		}
+		return
+	}
+
+	sendJSON(res, rowsResult)
+}
+
+const defaultDistinctValuesLimit = 100
+
+// Expects:
+//   - query parameter 'table': name of table to get values from
+//   - query parameter 'column': name of column to get distinct values of
+//   - query parameter 'prefix' (optional): only return values starting with this
+//     (TEXT/UUID/CATEGORY columns only)
+//   - query parameter 'limit' (optional): maximum number of values to return (default 100)
+//
+// Returns:
+//   - JSON-encoded []db.DistinctValue, sorted by descending count
+func (api AnalysisAPI) GetDistinctColumnValues(res http.ResponseWriter, req *http.Request) {
+	params := req.URL.Query()
+
+	table := params.Get("table")
+	if table == "" {
+		sendClientError(res, nil, "missing 'table' query parameter in request")
+		return
+	}
+
+	valuesQuery := db.DistinctValuesQuery{
+		FieldName:    params.Get("column"),
+		SearchPrefix: params.Get("prefix"),
+		Limit:        defaultDistinctValuesLimit,
+	}
+	if valuesQuery.FieldName == "" {
+		sendClientError(res, nil, "missing 'column' query parameter in request")
+		return
+	}
+	if limit := params.Get("limit"); limit != "" {
+		var err error
+		if valuesQuery.Limit, err = strconv.Atoi(limit); err != nil {
+			sendClientError(res, err, "invalid 'limit' query parameter")
+			return
+		}
+	}
+
+	schema, err := api.db.GetTableSchema(req.Context(), table)
+	if err != nil {
+		sendServerError(res, err, "failed to get table schema")
+		return
+	}
+
+	if _, err := valuesQuery.Validate(schema); err != nil {
+		sendClientError(res, err, "invalid distinct values query")
+		return
+	}
+
+	values, err := api.db.QueryDistinctValues(req.Context(), valuesQuery, schema)
+	if err != nil {
+		sendServerError(res, err, "failed to get distinct column values")
+		return
+	}
+
+	sendJSON(res, values)
+}
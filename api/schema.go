@@ -1,9 +1,12 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"hermannm.dev/analysis/csv"
+	"hermannm.dev/analysis/db"
+	"hermannm.dev/wrap"
 )
 
 // Expects:
@@ -27,32 +30,138 @@ func (api AnalysisAPI) GetTableSchema(res http.ResponseWriter, req *http.Request
 	sendJSON(res, schema)
 }
 
-const maxRowsToCheckForCSVSchemaDeduction = 100
+// Returned by DeduceCSVTableSchema. Embeds the schema, so clients that only need the schema can
+// treat the response as a db.TableSchema.
+type DeducedTableSchema struct {
+	db.TableSchema
+	Report db.SchemaDeductionReport `json:"report"`
+	// Whether the CSV file has a header row, as given in headerOptions or detected from the file.
+	// Ingestion assumes a header row by default, so clients should send this back as hasHeaderRow
+	// in headerOptions when ingesting the file.
+	HasHeaderRow bool `json:"hasHeaderRow"`
+}
 
 // Expects:
-//   - multipart form field 'csvFile': CSV file to deduce types from
+//   - multipart form field 'csvFile': CSV file to deduce types from, optionally compressed with
+//     gzip, zstd or zip
+//   - multipart form field 'encoding' (optional): encoding of the CSV file (see csv.Encoding),
+//     detected from the file if omitted
+//   - multipart form field 'parseOptions' (optional): JSON-encoded db.ParseOptions
+//   - multipart form field 'samplingOptions' (optional): JSON-encoded csv.SamplingOptions, for
+//     which rows to check (defaults to the first 100)
+//   - multipart form field 'headerOptions' (optional): JSON-encoded csv.HeaderOptions, for files
+//     without a header row or with custom column names (detects header row by default)
 //
 // Returns:
-//   - JSON-encoded db.TableSchema (with blank table name)
+//   - JSON-encoded DeducedTableSchema (with blank table name)
 func (api AnalysisAPI) DeduceCSVTableSchema(res http.ResponseWriter, req *http.Request) {
-	csvFile, _, err := req.FormFile("csvFile")
+	csvFile, err := getMultipartCSVFile(req)
 	if err != nil {
-		sendClientError(res, err, "failed to get file upload from request")
+		sendClientError(res, err, "")
 		return
 	}
 	defer csvFile.Close()
 
-	csvReader, err := csv.NewReader(csvFile, false)
+	parseOptions, err := getParseOptionsFromRequest(req)
+	if err != nil {
+		sendClientError(res, err, "")
+		return
+	}
+
+	samplingOptions, err := getSamplingOptionsFromRequest(req)
+	if err != nil {
+		sendClientError(res, err, "")
+		return
+	}
+
+	headerOptions, err := getHeaderOptionsFromRequest(req)
+	if err != nil {
+		sendClientError(res, err, "")
+		return
+	}
+
+	encoding, err := getEncodingFromRequest(req)
+	if err != nil {
+		sendClientError(res, err, "")
+		return
+	}
+
+	csvReader, err := csv.NewReader(csvFile, false, encoding)
 	if err != nil {
 		sendServerError(res, err, "failed to read uploaded CSV file")
 		return
 	}
 
-	schema, err := csvReader.DeduceTableSchema(maxRowsToCheckForCSVSchemaDeduction)
+	schema, report, hasHeaderRow, err := csvReader.DeduceTableSchema(
+		headerOptions,
+		samplingOptions,
+		parseOptions,
+	)
 	if err != nil {
 		sendServerError(res, err, "failed to deduce table schema from uploaded CSV")
 		return
 	}
 
-	sendJSON(res, schema)
+	sendJSON(res, DeducedTableSchema{
+		TableSchema:  schema,
+		Report:       report,
+		HasHeaderRow: hasHeaderRow,
+	})
+}
+
+func getParseOptionsFromRequest(req *http.Request) (db.ParseOptions, error) {
+	var parseOptions db.ParseOptions
+
+	parseOptionsInput := req.FormValue("parseOptions")
+	if parseOptionsInput == "" {
+		return parseOptions, nil
+	}
+	if err := json.Unmarshal([]byte(parseOptionsInput), &parseOptions); err != nil {
+		return db.ParseOptions{}, wrap.Error(err, "failed to parse 'parseOptions' field in request")
+	}
+	if err := parseOptions.Validate(); err != nil {
+		return db.ParseOptions{}, wrap.Error(err, "invalid parse options")
+	}
+
+	return parseOptions, nil
+}
+
+func getSamplingOptionsFromRequest(req *http.Request) (csv.SamplingOptions, error) {
+	var samplingOptions csv.SamplingOptions
+
+	samplingOptionsInput := req.FormValue("samplingOptions")
+	if samplingOptionsInput == "" {
+		return samplingOptions, nil
+	}
+	if err := json.Unmarshal([]byte(samplingOptionsInput), &samplingOptions); err != nil {
+		return csv.SamplingOptions{}, wrap.Error(
+			err,
+			"failed to parse 'samplingOptions' field in request",
+		)
+	}
+	if err := samplingOptions.Validate(); err != nil {
+		return csv.SamplingOptions{}, wrap.Error(err, "invalid sampling options")
+	}
+
+	return samplingOptions, nil
+}
+
+func getHeaderOptionsFromRequest(req *http.Request) (csv.HeaderOptions, error) {
+	var headerOptions csv.HeaderOptions
+
+	headerOptionsInput := req.FormValue("headerOptions")
+	if headerOptionsInput == "" {
+		return headerOptions, nil
+	}
+	if err := json.Unmarshal([]byte(headerOptionsInput), &headerOptions); err != nil {
+		return csv.HeaderOptions{}, wrap.Error(
+			err,
+			"failed to parse 'headerOptions' field in request",
+		)
+	}
+	if err := headerOptions.Validate(); err != nil {
+		return csv.HeaderOptions{}, wrap.Error(err, "invalid header options")
+	}
+
+	return headerOptions, nil
 }
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"slices"
+
+	"hermannm.dev/analysis/csv"
+	"hermannm.dev/devlog/log"
+	"hermannm.dev/wrap"
+)
+
+// Content types for which we read the CSV file from the raw request body instead of a multipart
+// form. Compressed bodies may also use text/csv, with a Content-Encoding header.
+var rawBodyMediaTypes = []string{
+	"text/csv",
+	"application/gzip",
+	"application/zstd",
+	"application/zip",
+}
+
+// Returns the raw request body if the request has one of rawBodyMediaTypes as Content-Type, or
+// otherwise the multipart form field 'csvFile' (see getMultipartCSVFile).
+//
+// Raw bodies are decompressed according to their Content-Encoding header, or if it is not set, the
+// magic bytes at the start of the body. Gzip and zstd bodies are decompressed as they are streamed,
+// while zip archives are stored in a temporary file first, since they can't be streamed.
+func getCSVFileFromRequest(req *http.Request) (io.ReadCloser, error) {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || !slices.Contains(rawBodyMediaTypes, mediaType) {
+		return getMultipartCSVFile(req)
+	}
+
+	compression, err := csv.ParseContentEncoding(req.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, wrap.Error(err, "invalid Content-Encoding header in request")
+	}
+
+	body := bufio.NewReader(req.Body)
+	if compression == 0 {
+		if compression, err = csv.DetectStreamCompression(body); err != nil {
+			return nil, err
+		}
+	}
+
+	if compression != csv.CompressionZip {
+		decompressed, err := csv.NewStreamDecompressingReader(body, compression)
+		if err != nil {
+			return nil, wrap.Errorf(err, "failed to decompress %v request body", compression)
+		}
+		return decompressed, nil
+	}
+
+	zipFile, _, err := copyToTempFile(body)
+	if err != nil {
+		return nil, wrap.Error(err, "failed to store uploaded zip archive")
+	}
+	decompressed, err := csv.NewDecompressingReader(zipFile)
+	if err != nil {
+		removeTempFile(zipFile)
+		return nil, wrap.Error(err, "failed to decompress uploaded zip archive")
+	}
+	return decompressedUpload{ReadSeekCloser: decompressed, upload: tempFileRemover{zipFile}}, nil
+}
+
+// Returns the multipart form field 'csvFile', decompressed if its magic bytes show that it is
+// compressed with gzip, zstd or zip.
+func getMultipartCSVFile(req *http.Request) (io.ReadSeekCloser, error) {
+	csvFile, _, err := req.FormFile("csvFile")
+	if err != nil {
+		return nil, wrap.Error(err, "failed to get CSV file from request")
+	}
+
+	decompressed, err := csv.NewDecompressingReader(csvFile)
+	if err != nil {
+		csvFile.Close()
+		return nil, wrap.Error(err, "failed to decompress uploaded CSV file")
+	}
+
+	return decompressedUpload{ReadSeekCloser: decompressed, upload: csvFile}, nil
+}
+
+// Closes both the decompressing reader and the upload it reads from.
+type decompressedUpload struct {
+	io.ReadSeekCloser
+	upload io.Closer
+}
+
+func (upload decompressedUpload) Close() error {
+	err := upload.ReadSeekCloser.Close()
+	if uploadErr := upload.upload.Close(); err == nil {
+		err = uploadErr
+	}
+	return err
+}
+
+func copyToTempFile(file io.Reader) (tempFile *os.File, size int64, err error) {
+	tempFile, err = os.CreateTemp("", "analysis-upload-*")
+	if err != nil {
+		return nil, 0, wrap.Error(err, "failed to create temporary file")
+	}
+
+	size, err = io.Copy(tempFile, file)
+	if err == nil {
+		_, err = tempFile.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		removeTempFile(tempFile)
+		return nil, 0, wrap.Error(err, "failed to write temporary file")
+	}
+
+	return tempFile, size, nil
+}
+
+func removeTempFile(tempFile *os.File) {
+	tempFile.Close()
+	if err := os.Remove(tempFile.Name()); err != nil {
+		log.ErrorCause(err, "failed to remove temporary file")
+	}
+}
+
+type tempFileRemover struct {
+	file *os.File
+}
+
+func (remover tempFileRemover) Close() error {
+	removeTempFile(remover.file)
+	return nil
+}
@@ -56,7 +56,12 @@ func BenchmarkIngestion(b *testing.B) {
 	schema := newSchema("ingestion_test")
 	withTestTable(b, schema, func(reader *csv.Reader) {
 		for i := 0; i < b.N; i++ {
-			if err := database.IngestData(context.Background(), reader, schema); err != nil {
+			if _, err := database.IngestData(
+				context.Background(),
+				reader,
+				schema,
+				db.IngestionOptions{},
+			); err != nil {
 				b.Fatal(err)
 			}
 
@@ -141,12 +146,17 @@ func withTestTable(b *testing.B, schema db.TableSchema, testFunc func(*csv.Reade
 	}
 	defer testFile.Close()
 
-	reader, err := csv.NewReader(testFile.(io.ReadSeeker), true)
+	reader, err := csv.NewReader(testFile.(io.ReadSeeker), true, 0)
 	if err != nil {
 		b.Fatal(wrap.Error(err, "failed to create reader for CSV test file"))
 	}
 
-	if err := database.IngestData(context.Background(), reader, schema); err != nil {
+	if _, err := database.IngestData(
+		context.Background(),
+		reader,
+		schema,
+		db.IngestionOptions{},
+	); err != nil {
 		b.Fatal(wrap.Errorf(err, "failed to insert test data in table '%s'", schema.TableName))
 	}
 	if err := reader.ResetReadPosition(true); err != nil {
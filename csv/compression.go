@@ -0,0 +1,253 @@
+package csv
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"hermannm.dev/enumnames"
+	"hermannm.dev/wrap"
+)
+
+// Compression format of an uploaded CSV file. Compressed files are decompressed before parsing.
+type Compression int8
+
+const (
+	CompressionGzip Compression = iota + 1
+	CompressionZstd
+	// A zip archive containing a single CSV file.
+	CompressionZip
+)
+
+var compressionMap = enumnames.NewMap(map[Compression]string{
+	CompressionGzip: "GZIP",
+	CompressionZstd: "ZSTD",
+	CompressionZip:  "ZIP",
+})
+
+func (compression Compression) IsValid() bool {
+	return compressionMap.ContainsKey(compression)
+}
+
+func (compression Compression) String() string {
+	return compressionMap.GetNameOrFallback(compression, "INVALID_COMPRESSION")
+}
+
+func (compression Compression) MarshalJSON() ([]byte, error) {
+	return compressionMap.MarshalToNameJSON(compression)
+}
+
+func (compression *Compression) UnmarshalJSON(bytes []byte) error {
+	return compressionMap.UnmarshalFromNameJSON(bytes, compression)
+}
+
+// Parses the compression from an HTTP Content-Encoding header. Returns 0 if the header is blank or
+// "identity".
+// See https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Content-Encoding
+func ParseContentEncoding(contentEncoding string) (Compression, error) {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return 0, nil
+	case "gzip", "x-gzip":
+		return CompressionGzip, nil
+	case "zstd":
+		return CompressionZstd, nil
+	default:
+		return 0, fmt.Errorf(
+			"unsupported content encoding '%s' (supported: gzip, zstd)",
+			contentEncoding,
+		)
+	}
+}
+
+// See https://en.wikipedia.org/wiki/List_of_file_signatures
+var (
+	magicGzip = []byte{0x1F, 0x8B}
+	magicZstd = []byte{0x28, 0xB5, 0x2F, 0xFD}
+	magicZip  = []byte{0x50, 0x4B, 0x03, 0x04}
+)
+
+const compressionDetectionPrefixSize = 4
+
+// Detects the compression of a file from the magic bytes at the start of it. Returns 0 if the file
+// is not compressed.
+func detectCompressionFromPrefix(prefix []byte) Compression {
+	switch {
+	case bytes.HasPrefix(prefix, magicGzip):
+		return CompressionGzip
+	case bytes.HasPrefix(prefix, magicZstd):
+		return CompressionZstd
+	case bytes.HasPrefix(prefix, magicZip):
+		return CompressionZip
+	default:
+		return 0
+	}
+}
+
+// Detects the compression of the given stream from its magic bytes, without consuming them.
+func DetectStreamCompression(stream *bufio.Reader) (Compression, error) {
+	prefix, err := stream.Peek(compressionDetectionPrefixSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, wrap.Error(err, "failed to read start of CSV stream")
+	}
+	return detectCompressionFromPrefix(prefix), nil
+}
+
+// Returns a reader that decompresses the given file, if its magic bytes show that it is compressed.
+// Otherwise, the file is returned as is. Zip archives must also implement io.ReaderAt.
+//
+// Like the transcoding reader from NewReader, the returned reader only supports seeking to the
+// start of the file, which it does by decompressing the file again from the start.
+func NewDecompressingReader(file io.ReadSeeker) (io.ReadSeekCloser, error) {
+	prefix, _, err := readPrefix(file, compressionDetectionPrefixSize)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, wrap.Error(err, "failed to reset CSV reader after detecting compression")
+	}
+
+	reader := &decompressingReader{}
+
+	switch compression := detectCompressionFromPrefix(prefix); compression {
+	case CompressionGzip, CompressionZstd:
+		reader.open = func() (io.ReadCloser, error) {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return newDecompressor(file, compression)
+		}
+	case CompressionZip:
+		csvFile, err := findCSVFileInZip(file)
+		if err != nil {
+			return nil, err
+		}
+		reader.open = csvFile.Open
+	default:
+		return nopSeekCloser{file}, nil
+	}
+
+	if reader.current, err = reader.open(); err != nil {
+		return nil, wrap.Error(err, "failed to start decompressing CSV file")
+	}
+	return reader, nil
+}
+
+// Like NewDecompressingReader, but for streams with the given compression. Zip archives are not
+// supported, since their index of files is at the end of the archive.
+func NewStreamDecompressingReader(
+	stream io.Reader,
+	compression Compression,
+) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionGzip, CompressionZstd:
+		return newDecompressor(stream, compression)
+	case CompressionZip:
+		return nil, errors.New("zip archives can't be decompressed as streams")
+	default:
+		return io.NopCloser(stream), nil
+	}
+}
+
+func newDecompressor(compressed io.Reader, compression Compression) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewReader(compressed)
+	case CompressionZstd:
+		// A single goroutine is enough, since decompressing is much faster than ingesting
+		decoder, err := zstd.NewReader(compressed, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %v", compression)
+	}
+}
+
+// Returns the only file in the given zip archive, or if it has several, the only one with a .csv
+// extension.
+func findCSVFileInZip(archive io.ReadSeeker) (*zip.File, error) {
+	archiveAt, ok := archive.(io.ReaderAt)
+	if !ok {
+		return nil, errors.New("zip archive does not support random access")
+	}
+	size, err := archive.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, wrap.Error(err, "failed to get size of zip archive")
+	}
+
+	zipReader, err := zip.NewReader(archiveAt, size)
+	if err != nil {
+		return nil, wrap.Error(err, "failed to read zip archive")
+	}
+
+	var files, csvFiles []*zip.File
+	for _, file := range zipReader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		files = append(files, file)
+		if strings.EqualFold(path.Ext(file.Name), ".csv") {
+			csvFiles = append(csvFiles, file)
+		}
+	}
+
+	switch {
+	case len(files) == 1:
+		return files[0], nil
+	case len(csvFiles) == 1:
+		return csvFiles[0], nil
+	case len(files) == 0:
+		return nil, errors.New("zip archive is empty")
+	default:
+		return nil, fmt.Errorf(
+			"zip archive must contain a single CSV file, but found %d",
+			len(csvFiles),
+		)
+	}
+}
+
+type decompressingReader struct {
+	// Starts decompressing from the start of the compressed file.
+	open    func() (io.ReadCloser, error)
+	current io.ReadCloser
+}
+
+func (reader *decompressingReader) Read(buffer []byte) (int, error) {
+	return reader.current.Read(buffer)
+}
+
+// Only supports seeking to the start of the file, which is all that Reader needs.
+func (reader *decompressingReader) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, errors.New("compressed CSV files only support seeking to the start")
+	}
+
+	reader.current.Close()
+	current, err := reader.open()
+	if err != nil {
+		return 0, wrap.Error(err, "failed to restart decompressing CSV file")
+	}
+	reader.current = current
+	return 0, nil
+}
+
+func (reader *decompressingReader) Close() error {
+	return reader.current.Close()
+}
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,216 @@
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+
+	"hermannm.dev/wrap"
+)
+
+// How fields and records are written in a CSV file.
+type Dialect struct {
+	Delimiter rune `json:"delimiter"`
+	// Character around fields that contain delimiters, quotes or newlines. Either double or single
+	// quote.
+	Quote rune `json:"quote"`
+	// Lines starting with this character are skipped. 0 if the file has no comment lines.
+	Comment rune `json:"comment,omitempty"`
+}
+
+var DefaultDelimitersToCheck = []rune{',', ';', '\t', ' ', '|'}
+
+// Quote characters that we check when deducing dialects, in order of preference.
+var quotesToCheck = []rune{'"', '\''}
+
+const commentCharToCheck = '#'
+
+// Number of bytes at the start of a file to parse when deducing its dialect.
+const dialectDeductionPrefixSize = 1024 * 1024
+
+// Number of records that Reader parses when deducing the dialect of a file.
+const dialectDeductionRowCount = 20
+
+// Deduces the dialect of the given CSV file by parsing up to maxRowsToCheck records from the start
+// of it with each combination of candidate delimiter, quote character and comment character. The
+// combination where the most records have the same number of fields wins, and on equal consistency,
+// the one giving the most fields. Combinations where most records have a single field only win if
+// no combination splits records, and combinations that fail to parse are discarded. Single quotes
+// and comment lines are only chosen if they give strictly better results than the alternatives,
+// since they are less common.
+//
+// Resets the read position to the start of the file before returning.
+func DeduceDialect(
+	csvFile io.ReadSeeker,
+	maxRowsToCheck int,
+	delimitersToCheck []rune,
+) (dialect Dialect, err error) {
+	// Resets reader position in file before returning, so its data can be read subsequently
+	defer func() {
+		if _, seekErr := csvFile.Seek(0, io.SeekStart); seekErr != nil && err == nil {
+			err = wrap.Error(seekErr, "failed to reset CSV reader after deducing dialect")
+		}
+	}()
+
+	prefix, isTruncated, err := readPrefix(csvFile, dialectDeductionPrefixSize)
+	if err != nil {
+		return Dialect{}, err
+	}
+
+	return deduceDialectFromPrefix(prefix, isTruncated, maxRowsToCheck, delimitersToCheck), nil
+}
+
+func deduceDialectFromPrefix(
+	prefix []byte,
+	isTruncated bool,
+	maxRowsToCheck int,
+	delimitersToCheck []rune,
+) Dialect {
+	if len(delimitersToCheck) == 0 {
+		delimitersToCheck = DefaultDelimitersToCheck
+	}
+
+	// If the prefix was cut off from a longer file, we drop its last partial line
+	if isTruncated {
+		if lastNewline := bytes.LastIndexByte(prefix, '\n'); lastNewline != -1 {
+			prefix = prefix[:lastNewline+1]
+		}
+	}
+
+	commentChars := []rune{0}
+	if bytes.HasPrefix(prefix, []byte{commentCharToCheck}) ||
+		bytes.Contains(prefix, []byte{'\n', commentCharToCheck}) {
+		commentChars = append(commentChars, commentCharToCheck)
+	}
+
+	best := dialectScore{
+		dialect: Dialect{Delimiter: delimitersToCheck[0], Quote: quotesToCheck[0]},
+	}
+	for _, quote := range quotesToCheck {
+		if quote != '"' && !bytes.ContainsRune(prefix, quote) {
+			continue
+		}
+
+		for _, comment := range commentChars {
+			for _, delimiter := range delimitersToCheck {
+				candidate := Dialect{Delimiter: delimiter, Quote: quote, Comment: comment}
+
+				score, ok := scoreDialect(prefix, isTruncated, candidate, maxRowsToCheck)
+				if ok && score.isBetterThan(best) {
+					best = score
+				}
+			}
+		}
+	}
+
+	return best.dialect
+}
+
+type dialectScore struct {
+	dialect Dialect
+	// Share of the checked records that have the most common number of fields, between 0 and 1.
+	consistency float64
+	// The most common number of fields in the checked records.
+	fieldCount int
+}
+
+func (score dialectScore) isBetterThan(other dialectScore) bool {
+	// A delimiter that doesn't appear in the file gives 1 field on every record, which is perfectly
+	// consistent, so we only choose single-field results if no candidate splits records
+	if (score.fieldCount > 1) != (other.fieldCount > 1) {
+		return score.fieldCount > 1
+	}
+	if score.consistency != other.consistency {
+		return score.consistency > other.consistency
+	}
+	return score.fieldCount > other.fieldCount
+}
+
+// Parses records from the given prefix with the given dialect. Returns false if parsing fails,
+// unless the prefix was cut off from a longer file and we have already parsed some records, in
+// which case the failing record may just be cut off in the middle of a quoted field.
+func scoreDialect(
+	prefix []byte,
+	isTruncated bool,
+	dialect Dialect,
+	maxRowsToCheck int,
+) (score dialectScore, ok bool) {
+	reader := newInnerReader(bytes.NewReader(prefix), dialect)
+
+	fieldCounts := make(map[int]int)
+	recordCount := 0
+	for recordCount < maxRowsToCheck {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if isTruncated && recordCount > 0 {
+				break
+			}
+			return dialectScore{}, false
+		}
+
+		fieldCounts[len(record)]++
+		recordCount++
+	}
+	if recordCount == 0 {
+		return dialectScore{}, false
+	}
+
+	score.dialect = dialect
+	mostCommonCount := 0
+	for fieldCount, count := range fieldCounts {
+		if count > mostCommonCount || (count == mostCommonCount && fieldCount > score.fieldCount) {
+			mostCommonCount = count
+			score.fieldCount = fieldCount
+		}
+	}
+	score.consistency = float64(mostCommonCount) / float64(recordCount)
+	return score, true
+}
+
+// Returns a CSV reader for the given dialect. encoding/csv only supports double quotes, so for
+// files quoted with single quotes, we swap the two in the input, and swap them back in the parsed
+// fields (see Reader.ReadRow).
+func newInnerReader(csvFile io.Reader, dialect Dialect) *csv.Reader {
+	if dialect.Quote == '\'' {
+		csvFile = quoteSwappingReader{csvFile}
+	}
+
+	reader := csv.NewReader(csvFile)
+	reader.ReuseRecord = true
+	reader.Comma = dialect.Delimiter
+	reader.Comment = dialect.Comment
+	// Returns rows with the wrong number of fields instead of failing, so that lenient ingestion
+	// can reject them individually (see db.IngestionReport.RejectRow)
+	reader.FieldsPerRecord = -1
+	return reader
+}
+
+type quoteSwappingReader struct {
+	inner io.Reader
+}
+
+func (reader quoteSwappingReader) Read(buffer []byte) (int, error) {
+	n, err := reader.inner.Read(buffer)
+	for i, char := range buffer[:n] {
+		switch char {
+		case '"':
+			buffer[i] = '\''
+		case '\'':
+			buffer[i] = '"'
+		}
+	}
+	return n, err
+}
+
+var quoteSwapper = strings.NewReplacer(`"`, `'`, `'`, `"`)
+
+func swapQuotes(row []string) {
+	for i, field := range row {
+		row[i] = quoteSwapper.Replace(field)
+	}
+}
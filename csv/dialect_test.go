@@ -0,0 +1,211 @@
+package csv
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDeduceDialect(t *testing.T) {
+	testCases := []struct {
+		name string
+		file string
+		want Dialect
+	}{
+		{
+			name: "Comma",
+			file: "name,price,count\napple,1.5,3\npear,2.25,4\n",
+			want: Dialect{Delimiter: ',', Quote: '"'},
+		},
+		{
+			name: "Semicolon with decimal commas",
+			file: "name;price\napple;1,50\npear;2,25\nplum;3\n",
+			want: Dialect{Delimiter: ';', Quote: '"'},
+		},
+		{
+			name: "Semicolon with commas in quoted fields",
+			file: "\"Smith, John\";42\n\"Doe, Jane\";37\n",
+			want: Dialect{Delimiter: ';', Quote: '"'},
+		},
+		{
+			name: "Comma with semicolons in quoted fields",
+			file: "\"a;b;c\",1\n\"d;e\",2\n\"f\",3\n",
+			want: Dialect{Delimiter: ',', Quote: '"'},
+		},
+		{
+			name: "Quoted field with newline",
+			file: "name,address\nJohn,\"Main Street 1\nOslo\"\nJane,\"Side Street 2\"\n",
+			want: Dialect{Delimiter: ',', Quote: '"'},
+		},
+		{
+			name: "Tab",
+			file: "name\tprice\napple\t1.5\npear\t2.25\n",
+			want: Dialect{Delimiter: '\t', Quote: '"'},
+		},
+		{
+			name: "Single quotes",
+			file: "name,city\n'Smith, John',Oslo\nJane,'Bergen'\n",
+			want: Dialect{Delimiter: ',', Quote: '\''},
+		},
+		{
+			name: "Apostrophes in unquoted fields",
+			file: "name,comment\nJohn,it's fine\nJane,don't\n",
+			want: Dialect{Delimiter: ',', Quote: '"'},
+		},
+		{
+			name: "Comment lines",
+			file: "# Exported from accounting\n# 2023-10-01\nname,price\napple,1.5\npear,2.25\n",
+			want: Dialect{Delimiter: ',', Quote: '"', Comment: '#'},
+		},
+		{
+			name: "Hash in data",
+			file: "id,name\n#1,apple\n#2,pear\n",
+			want: Dialect{Delimiter: ',', Quote: '"'},
+		},
+		{
+			name: "Ragged row",
+			file: "a,b\n1,2\n3\n4,5\n",
+			want: Dialect{Delimiter: ',', Quote: '"'},
+		},
+		{
+			name: "Ragged row with decimal points",
+			file: "name,price,count\napple,1.5,3\npear,2.25\nplum,3,1\nfig,0.5,8\n",
+			want: Dialect{Delimiter: ',', Quote: '"'},
+		},
+		{
+			name: "Preamble line",
+			file: "Report for 2023\nid,name\n1,a\n2,b\n3,c\n",
+			want: Dialect{Delimiter: ',', Quote: '"'},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			dialect, err := DeduceDialect(
+				strings.NewReader(testCase.file),
+				dialectDeductionRowCount,
+				DefaultDelimitersToCheck,
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if dialect != testCase.want {
+				t.Errorf("expected dialect %+v, got %+v", testCase.want, dialect)
+			}
+		})
+	}
+}
+
+func TestDeduceDialectFromTruncatedPrefix(t *testing.T) {
+	testCases := []struct {
+		name   string
+		prefix string
+		want   Dialect
+	}{
+		{
+			name:   "Truncated in unquoted field",
+			prefix: "name;price\napple;1,50\npear;2,25\nplum;3,",
+			want:   Dialect{Delimiter: ';', Quote: '"'},
+		},
+		{
+			name:   "Truncated in quoted field",
+			prefix: "name;price\napple;1,50\npear;2,25\n\"plum, red;3",
+			want:   Dialect{Delimiter: ';', Quote: '"'},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			dialect := deduceDialectFromPrefix(
+				[]byte(testCase.prefix),
+				true,
+				dialectDeductionRowCount,
+				DefaultDelimitersToCheck,
+			)
+			if dialect != testCase.want {
+				t.Errorf("expected dialect %+v, got %+v", testCase.want, dialect)
+			}
+		})
+	}
+}
+
+func TestReaderDialects(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		wantRows [][]string
+	}{
+		{
+			name:  "Semicolon",
+			input: "name;price\napple;1,50\npear;2,25\n",
+			wantRows: [][]string{
+				{"name", "price"},
+				{"apple", "1,50"},
+				{"pear", "2,25"},
+			},
+		},
+		{
+			name:  "Single quotes",
+			input: "name,comment\n'Smith, John','said \"hi\"'\nJane,'it''s fine'\n",
+			wantRows: [][]string{
+				{"name", "comment"},
+				{"Smith, John", `said "hi"`},
+				{"Jane", "it's fine"},
+			},
+		},
+		{
+			name:  "Comment lines",
+			input: "# Exported from accounting\nname,price\n# Fruit\napple,1.5\npear,2.25\n",
+			wantRows: [][]string{
+				{"name", "price"},
+				{"apple", "1.5"},
+				{"pear", "2.25"},
+			},
+		},
+		{
+			name:     "Short row",
+			input:    "a,b\n1,2\n3\n4,5\n",
+			wantRows: [][]string{{"a", "b"}, {"1", "2"}, {"3"}, {"4", "5"}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		for _, stream := range []bool{false, true} {
+			name := testCase.name
+			if stream {
+				name += " from stream"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				input := strings.NewReader(testCase.input)
+				var reader *Reader
+				var err error
+				if stream {
+					reader, err = NewStreamReader(input, false, EncodingUTF8)
+				} else {
+					reader, err = NewReader(input, false, EncodingUTF8)
+				}
+				if err != nil {
+					t.Fatalf("unexpected error when creating reader: %v", err)
+				}
+
+				var rows [][]string
+				for {
+					row, _, done, err := reader.ReadRow()
+					if done {
+						break
+					}
+					if err != nil {
+						t.Fatalf("unexpected error when reading row: %v", err)
+					}
+					// The reader reuses its row slice, so we clone it
+					rows = append(rows, slices.Clone(row))
+				}
+
+				if !slices.EqualFunc(rows, testCase.wantRows, slices.Equal[[]string]) {
+					t.Errorf("expected rows %q, got %q", testCase.wantRows, rows)
+				}
+			})
+		}
+	}
+}
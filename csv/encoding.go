@@ -0,0 +1,334 @@
+package csv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"hermannm.dev/enumnames"
+	"hermannm.dev/wrap"
+)
+
+// Character encoding of a CSV file. Files in other encodings than UTF-8 are transcoded to UTF-8
+// before parsing.
+type Encoding int8
+
+const (
+	EncodingUTF8 Encoding = iota + 1
+	EncodingUTF16LE
+	EncodingUTF16BE
+	// Used by Excel on Windows for CSV exports in Western European locales. Also covers ISO-8859-1,
+	// which it is a superset of for printable characters.
+	EncodingWindows1252
+)
+
+var encodingMap = enumnames.NewMap(map[Encoding]string{
+	EncodingUTF8:        "UTF-8",
+	EncodingUTF16LE:     "UTF-16LE",
+	EncodingUTF16BE:     "UTF-16BE",
+	EncodingWindows1252: "WINDOWS-1252",
+})
+
+func (encoding Encoding) IsValid() bool {
+	return encodingMap.ContainsKey(encoding)
+}
+
+func (encoding Encoding) String() string {
+	return encodingMap.GetNameOrFallback(encoding, "INVALID_ENCODING")
+}
+
+func (encoding Encoding) MarshalJSON() ([]byte, error) {
+	return encodingMap.MarshalToNameJSON(encoding)
+}
+
+func (encoding *Encoding) UnmarshalJSON(bytes []byte) error {
+	return encodingMap.UnmarshalFromNameJSON(bytes, encoding)
+}
+
+func ParseEncoding(name string) (Encoding, error) {
+	encoding, ok := encodingMap.GetKey(name)
+	if !ok {
+		return 0, fmt.Errorf("unsupported encoding '%s' (supported: %v)", name, encodingMap)
+	}
+	return encoding, nil
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Number of bytes at the start of a file to check when detecting its encoding.
+const encodingDetectionPrefixSize = 64 * 1024
+
+// Detects the encoding of the given file from its byte order mark (BOM), or if it has none, from
+// the bytes at the start of the file: UTF-16 text with mostly ASCII characters has a zero byte in
+// every other position, and text that is not valid UTF-8 is assumed to be Windows-1252.
+//
+// Returns the length of the BOM, which should be skipped when reading the file. Resets the read
+// position to the start of the file before returning.
+func DetectEncoding(file io.ReadSeeker) (encoding Encoding, bomLength int, err error) {
+	defer func() {
+		if _, seekErr := file.Seek(0, io.SeekStart); seekErr != nil && err == nil {
+			err = wrap.Error(seekErr, "failed to reset CSV reader after detecting encoding")
+		}
+	}()
+
+	prefix, isTruncated, err := readPrefix(file, encodingDetectionPrefixSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	encoding, bomLength = detectEncodingFromPrefix(prefix, isTruncated)
+	return encoding, bomLength, nil
+}
+
+// Reads up to prefixSize bytes from the start of the given file. If the file is longer than that,
+// isTruncated is true.
+func readPrefix(file io.Reader, prefixSize int) (prefix []byte, isTruncated bool, err error) {
+	prefix = make([]byte, prefixSize)
+	n, err := io.ReadFull(file, prefix)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, false, wrap.Error(err, "failed to read start of CSV file")
+	}
+	return prefix[:n], n == prefixSize, nil
+}
+
+func detectEncodingFromPrefix(prefix []byte, isTruncated bool) (encoding Encoding, bomLength int) {
+	switch {
+	case bytes.HasPrefix(prefix, bomUTF8):
+		return EncodingUTF8, len(bomUTF8)
+	case bytes.HasPrefix(prefix, bomUTF16LE):
+		return EncodingUTF16LE, len(bomUTF16LE)
+	case bytes.HasPrefix(prefix, bomUTF16BE):
+		return EncodingUTF16BE, len(bomUTF16BE)
+	}
+
+	if encoding, ok := detectUTF16WithoutBOM(prefix); ok {
+		return encoding, 0
+	}
+
+	if isValidUTF8Prefix(prefix, isTruncated) {
+		return EncodingUTF8, 0
+	}
+	return EncodingWindows1252, 0
+}
+
+func detectUTF16WithoutBOM(prefix []byte) (encoding Encoding, ok bool) {
+	pairCount := len(prefix) / 2
+	if pairCount == 0 {
+		return 0, false
+	}
+
+	var evenZeros, oddZeros int
+	for i := 0; i+1 < len(prefix); i += 2 {
+		if prefix[i] == 0 {
+			evenZeros++
+		}
+		if prefix[i+1] == 0 {
+			oddZeros++
+		}
+	}
+
+	// Requires a clear majority of zeros on one side, since zero bytes are rare in text files
+	const minZeroShare, maxOtherZeroShare = 0.3, 0.05
+	switch {
+	case float64(oddZeros) >= minZeroShare*float64(pairCount) &&
+		float64(evenZeros) <= maxOtherZeroShare*float64(pairCount):
+		return EncodingUTF16LE, true
+	case float64(evenZeros) >= minZeroShare*float64(pairCount) &&
+		float64(oddZeros) <= maxOtherZeroShare*float64(pairCount):
+		return EncodingUTF16BE, true
+	default:
+		return 0, false
+	}
+}
+
+// If the prefix was cut off from a longer file, it may end in the middle of a multi-byte
+// character, which we allow.
+func isValidUTF8Prefix(prefix []byte, isTruncated bool) bool {
+	if utf8.Valid(prefix) {
+		return true
+	}
+	if !isTruncated {
+		return false
+	}
+
+	for cutLength := 1; cutLength < utf8.UTFMax && cutLength <= len(prefix); cutLength++ {
+		cutIndex := len(prefix) - cutLength
+		if !utf8.FullRune(prefix[cutIndex:]) && utf8.Valid(prefix[:cutIndex]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a reader that transcodes the given file from the given encoding to UTF-8, skipping the
+// BOM. If the file is already UTF-8 without a BOM, it is returned as is.
+func newDecodingReader(file io.ReadSeeker, encoding Encoding, bomLength int) io.ReadSeeker {
+	if encoding == EncodingUTF8 && bomLength == 0 {
+		return file
+	}
+
+	return &decodingReader{
+		file:        file,
+		encoding:    encoding,
+		startOffset: int64(bomLength),
+		readBuffer:  make([]byte, 4096),
+		needsSeek:   true,
+	}
+}
+
+// Like newDecodingReader, but for streams that can't seek. Expects the BOM to have been skipped.
+func newStreamDecodingReader(stream io.Reader, encoding Encoding) io.Reader {
+	if encoding == EncodingUTF8 {
+		return stream
+	}
+
+	return &decodingReader{file: stream, encoding: encoding, readBuffer: make([]byte, 4096)}
+}
+
+type decodingReader struct {
+	// Only needs to implement io.Seeker if Seek is called.
+	file     io.Reader
+	encoding Encoding
+	// Where the text starts in the file, after any BOM.
+	startOffset int64
+	needsSeek   bool
+	readBuffer  []byte
+	// Bytes read from the file that could not be decoded yet, since they were cut off in the
+	// middle of a character.
+	undecoded []byte
+	// Decoded UTF-8 bytes that have not been returned from Read yet.
+	decoded []byte
+}
+
+func (reader *decodingReader) Read(buffer []byte) (int, error) {
+	if reader.needsSeek {
+		if err := reader.seekToStart(); err != nil {
+			return 0, err
+		}
+		reader.needsSeek = false
+	}
+
+	for len(reader.decoded) == 0 {
+		n, err := reader.file.Read(reader.readBuffer)
+		reader.undecoded = append(reader.undecoded, reader.readBuffer[:n]...)
+
+		atEOF := errors.Is(err, io.EOF)
+		reader.decode(atEOF)
+
+		if err != nil {
+			if atEOF && len(reader.decoded) != 0 {
+				break
+			}
+			return 0, err
+		}
+	}
+
+	n := copy(buffer, reader.decoded)
+	reader.decoded = reader.decoded[n:]
+	return n, nil
+}
+
+// Only supports seeking to the start of the file, which is all that Reader needs.
+func (reader *decodingReader) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, errors.New("transcoded CSV files only support seeking to the start")
+	}
+	if _, ok := reader.file.(io.Seeker); !ok {
+		return 0, errors.New("streamed CSV files can't seek")
+	}
+
+	reader.needsSeek = true
+	reader.undecoded = reader.undecoded[:0]
+	reader.decoded = nil
+	return 0, nil
+}
+
+func (reader *decodingReader) seekToStart() error {
+	seeker, ok := reader.file.(io.Seeker)
+	if !ok {
+		return errors.New("streamed CSV files can't seek")
+	}
+	_, err := seeker.Seek(reader.startOffset, io.SeekStart)
+	return err
+}
+
+func (reader *decodingReader) decode(atEOF bool) {
+	switch reader.encoding {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		reader.decodeUTF16(atEOF)
+	case EncodingWindows1252:
+		for _, char := range reader.undecoded {
+			reader.decoded = utf8.AppendRune(reader.decoded, windows1252ToRune(char))
+		}
+		reader.undecoded = reader.undecoded[:0]
+	default:
+		reader.decoded = append(reader.decoded, reader.undecoded...)
+		reader.undecoded = reader.undecoded[:0]
+	}
+}
+
+func (reader *decodingReader) decodeUTF16(atEOF bool) {
+	codeUnit := func(index int) uint16 {
+		if reader.encoding == EncodingUTF16LE {
+			return uint16(reader.undecoded[index]) | uint16(reader.undecoded[index+1])<<8
+		} else {
+			return uint16(reader.undecoded[index])<<8 | uint16(reader.undecoded[index+1])
+		}
+	}
+
+	i := 0
+	for i+1 < len(reader.undecoded) {
+		unit := rune(codeUnit(i))
+
+		if !utf16.IsSurrogate(unit) {
+			reader.decoded = utf8.AppendRune(reader.decoded, unit)
+			i += 2
+			continue
+		}
+
+		// Surrogate pairs are split over two code units, so we wait for the next one if it has
+		// not been read yet
+		if i+3 >= len(reader.undecoded) {
+			break
+		}
+
+		char := utf16.DecodeRune(unit, rune(codeUnit(i+2)))
+		reader.decoded = utf8.AppendRune(reader.decoded, char)
+		if char == utf8.RuneError {
+			// Invalid pair: only skips the first unit, as the second may start a valid character
+			i += 2
+		} else {
+			i += 4
+		}
+	}
+
+	reader.undecoded = append(reader.undecoded[:0], reader.undecoded[i:]...)
+
+	if atEOF && len(reader.undecoded) != 0 {
+		reader.decoded = utf8.AppendRune(reader.decoded, utf8.RuneError)
+		reader.undecoded = reader.undecoded[:0]
+	}
+}
+
+// Windows-1252 is the same as Unicode for 0x00-0x7F and 0xA0-0xFF, but has its own characters in
+// 0x80-0x9F. Unassigned bytes in that range are mapped to the Unicode code point with the same
+// value, like browsers do.
+// https://en.wikipedia.org/wiki/Windows-1252#Codepage_layout
+var windows1252Range0x80To0x9F = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+func windows1252ToRune(char byte) rune {
+	if char >= 0x80 && char <= 0x9F {
+		return windows1252Range0x80To0x9F[char-0x80]
+	}
+	return rune(char)
+}
@@ -0,0 +1,231 @@
+package csv
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	testCases := []struct {
+		name          string
+		prefix        string
+		isTruncated   bool
+		wantEncoding  Encoding
+		wantBOMLength int
+	}{
+		{
+			name:          "UTF-8 BOM",
+			prefix:        "\xEF\xBB\xBFname,price\n",
+			wantEncoding:  EncodingUTF8,
+			wantBOMLength: 3,
+		},
+		{
+			name:          "UTF-16LE BOM",
+			prefix:        "\xFF\xFEa\x00,\x00b\x00",
+			wantEncoding:  EncodingUTF16LE,
+			wantBOMLength: 2,
+		},
+		{
+			name:          "UTF-16BE BOM",
+			prefix:        "\xFE\xFF\x00a\x00,\x00b",
+			wantEncoding:  EncodingUTF16BE,
+			wantBOMLength: 2,
+		},
+		{
+			name:         "UTF-16LE without BOM",
+			prefix:       "n\x00a\x00m\x00e\x00,\x00p\x00r\x00i\x00c\x00e\x00",
+			wantEncoding: EncodingUTF16LE,
+		},
+		{
+			name:         "UTF-16BE without BOM",
+			prefix:       "\x00n\x00a\x00m\x00e\x00,\x00p\x00r\x00i\x00c\x00e",
+			wantEncoding: EncodingUTF16BE,
+		},
+		{
+			name:         "UTF-8",
+			prefix:       "name,city\nJosé,Zürich\n",
+			wantEncoding: EncodingUTF8,
+		},
+		{
+			name:         "UTF-8 truncated in 2-byte character",
+			prefix:       "name,city\nJos\xC3",
+			isTruncated:  true,
+			wantEncoding: EncodingUTF8,
+		},
+		{
+			name:         "UTF-8 truncated in 3-byte character",
+			prefix:       "name,price\nTea,\xE2\x82",
+			isTruncated:  true,
+			wantEncoding: EncodingUTF8,
+		},
+		{
+			name:         "Incomplete character at end of whole file",
+			prefix:       "name,city\nJos\xC3",
+			isTruncated:  false,
+			wantEncoding: EncodingWindows1252,
+		},
+		{
+			name:         "Windows-1252",
+			prefix:       "name,price\nCaf\xE9,\x805\n",
+			wantEncoding: EncodingWindows1252,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			file := testCase.prefix
+			if testCase.isTruncated {
+				// Pads the start of the file so that the prefix ends where detection stops reading
+				padding := strings.Repeat("a", encodingDetectionPrefixSize-len(file))
+				file = padding + file + "more data\n"
+			}
+
+			encoding, bomLength, err := DetectEncoding(strings.NewReader(file))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if encoding != testCase.wantEncoding {
+				t.Errorf("expected encoding %v, got %v", testCase.wantEncoding, encoding)
+			}
+			if bomLength != testCase.wantBOMLength {
+				t.Errorf("expected BOM length %d, got %d", testCase.wantBOMLength, bomLength)
+			}
+		})
+	}
+}
+
+func TestDecodingReader(t *testing.T) {
+	testCases := []struct {
+		name     string
+		encoding Encoding
+		input    string
+		want     string
+	}{
+		{
+			name:     "UTF-16LE",
+			encoding: EncodingUTF16LE,
+			input:    "a\x00,\x00\xE9\x00\n\x00",
+			want:     "a,é\n",
+		},
+		{
+			name:     "UTF-16BE",
+			encoding: EncodingUTF16BE,
+			input:    "\x00a\x00,\x00\xE9\x00\n",
+			want:     "a,é\n",
+		},
+		{
+			name:     "UTF-16LE surrogate pair",
+			encoding: EncodingUTF16LE,
+			input:    "a\x00=\x00\x3D\xD8\x00\xDE!\x00",
+			want:     "a=😀!",
+		},
+		{
+			name:     "UTF-16BE surrogate pair",
+			encoding: EncodingUTF16BE,
+			input:    "\x00a\x00=\xD8\x3D\xDE\x00\x00!",
+			want:     "a=😀!",
+		},
+		{
+			name:     "UTF-16LE unpaired high surrogate",
+			encoding: EncodingUTF16LE,
+			input:    "\x3D\xD8a\x00",
+			want:     "�a",
+		},
+		{
+			name:     "UTF-16LE high surrogate at end of file",
+			encoding: EncodingUTF16LE,
+			input:    "a\x00\x3D\xD8",
+			want:     "a�",
+		},
+		{
+			name:     "UTF-16LE odd trailing byte",
+			encoding: EncodingUTF16LE,
+			input:    "a\x00b",
+			want:     "a�",
+		},
+		{
+			name:     "Windows-1252 ASCII and Latin-1",
+			encoding: EncodingWindows1252,
+			input:    "Caf\xE9 \xC6\xF8\xE5",
+			want:     "Café Æøå",
+		},
+		{
+			name:     "Windows-1252 0x80-0x9F",
+			encoding: EncodingWindows1252,
+			input:    "\x80\x82\x85\x8A\x93\x94\x96\x99\x9F",
+			want:     "€‚…Š“”–™Ÿ",
+		},
+		{
+			name:     "Windows-1252 unassigned bytes",
+			encoding: EncodingWindows1252,
+			input:    "\x81\x8D\x8F\x90\x9D",
+			want:     "\u0081\u008D\u008F\u0090\u009D",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Reading one byte at a time splits multi-byte characters across reads
+			readers := map[string]io.Reader{
+				"whole input": newDecodingReader(
+					bytes.NewReader([]byte(testCase.input)),
+					testCase.encoding,
+					0,
+				),
+				"one byte at a time": newDecodingReader(
+					oneByteReadSeeker{bytes.NewReader([]byte(testCase.input))},
+					testCase.encoding,
+					0,
+				),
+				"stream one byte at a time": newStreamDecodingReader(
+					iotest.OneByteReader(bytes.NewReader([]byte(testCase.input))),
+					testCase.encoding,
+				),
+			}
+
+			for readerName, reader := range readers {
+				decoded, err := io.ReadAll(reader)
+				if err != nil {
+					t.Fatalf("%s: unexpected error: %v", readerName, err)
+				}
+				if string(decoded) != testCase.want {
+					t.Errorf("%s: expected %q, got %q", readerName, testCase.want, decoded)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodingReaderSkipsBOMAfterSeek(t *testing.T) {
+	input := []byte("\xFF\xFEa\x00,\x00\x3D\xD8\x00\xDE")
+	reader := newDecodingReader(bytes.NewReader(input), EncodingUTF16LE, len(bomUTF16LE))
+
+	for i := 0; i < 2; i++ {
+		decoded, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("read %d: unexpected error: %v", i+1, err)
+		}
+		if want := "a,😀"; string(decoded) != want {
+			t.Errorf("read %d: expected %q, got %q", i+1, want, decoded)
+		}
+
+		if _, err := reader.Seek(0, io.SeekStart); err != nil {
+			t.Fatalf("unexpected error when seeking: %v", err)
+		}
+	}
+}
+
+// Like iotest.OneByteReader, but keeps the Seek method that newDecodingReader requires.
+type oneByteReadSeeker struct {
+	io.ReadSeeker
+}
+
+func (reader oneByteReadSeeker) Read(buffer []byte) (int, error) {
+	if len(buffer) == 0 {
+		return 0, nil
+	}
+	return reader.ReadSeeker.Read(buffer[:1])
+}
@@ -0,0 +1,147 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"hermannm.dev/analysis/db"
+	"hermannm.dev/wrap"
+)
+
+type HeaderOptions struct {
+	// Whether the first row of the file is a header row with column names. If nil, this is
+	// detected from the file (see Reader.DetectHeaderRow).
+	HasHeaderRow *bool `json:"hasHeaderRow,omitempty"`
+	// Column names to use instead of the header row, or for files without one. If empty, names
+	// are taken from the header row, or generated as "column_1", "column_2" etc. if there is none.
+	ColumnNames []string `json:"columnNames,omitempty"`
+}
+
+// Number of data rows to compare the first row against in DetectHeaderRow.
+const headerDetectionRowCount = 20
+
+// Detects whether the first row of the file is a header row, by deducing data types from the rows
+// after it and checking whether the first row fits them. If it fits, the first row is data like
+// the others. If all columns are TEXT, anything fits, so we assume that there is a header row.
+// The given parse options should be the same as for the rest of schema deduction, so that e.g.
+// custom true/false values and null values are recognized in the first row.
+//
+// Resets the read position to the start of the file before returning.
+func (reader *Reader) DetectHeaderRow(parseOptions db.ParseOptions) (hasHeaderRow bool, err error) {
+	defer func() {
+		if resetErr := reader.ResetReadPosition(false); resetErr != nil && err == nil {
+			err = wrap.Error(resetErr, "failed to reset CSV reader after detecting header row")
+		}
+	}()
+
+	firstRow, err := reader.ReadHeaderRow()
+	if err != nil {
+		return false, err
+	}
+	// The CSV reader reuses its row slice, so we copy the first row before reading more
+	firstRow = slices.Clone(firstRow)
+
+	deducer := db.NewSchemaDeducer(GenerateColumnNames(len(firstRow)), parseOptions)
+	for i := 0; i < headerDetectionRowCount; i++ {
+		row, rowNumber, done, err := reader.ReadRow()
+		if done {
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+		if err := deducer.DeduceDataTypesFromRow(row, rowNumber); err != nil {
+			return false, wrap.Errorf(err, "failed to parse CSV data types from row %d", rowNumber)
+		}
+	}
+
+	dataSchema, _ := deducer.Schema()
+
+	allText := true
+	for _, column := range dataSchema.Columns {
+		if column.DataType.IsValid() && column.DataType != db.DataTypeText &&
+			column.DataType != db.DataTypeCategory {
+			allText = false
+			break
+		}
+	}
+	if allText {
+		return true, nil
+	}
+
+	_, err = dataSchema.ConvertRowToMap(firstRow)
+	firstRowFitsData := err == nil
+	return !firstRowFitsData, nil
+}
+
+// Reads the column names for the file according to the given options, leaving the reader at the
+// first data row. Also returns whether the file has a header row, as given or detected.
+func (reader *Reader) readColumnNames(
+	options HeaderOptions,
+	parseOptions db.ParseOptions,
+) (columnNames []string, hasHeaderRow bool, err error) {
+	if options.HasHeaderRow != nil {
+		hasHeaderRow = *options.HasHeaderRow
+	} else {
+		hasHeaderRow, err = reader.DetectHeaderRow(parseOptions)
+		if err != nil {
+			return nil, false, wrap.Error(err, "failed to detect CSV header row")
+		}
+	}
+
+	if hasHeaderRow {
+		columnNames, err = reader.ReadHeaderRow()
+		if err != nil {
+			return nil, false, wrap.Error(err, "failed to read CSV column names from header row")
+		}
+	} else if len(options.ColumnNames) == 0 {
+		// Reads the first row to know the number of columns, then goes back to it
+		firstRow, err := reader.ReadHeaderRow()
+		if err != nil {
+			return nil, false, wrap.Error(err, "failed to read first CSV row")
+		}
+		columnNames = GenerateColumnNames(len(firstRow))
+
+		if err := reader.ResetReadPosition(false); err != nil {
+			return nil, false, wrap.Error(err, "failed to reset CSV reader after reading first row")
+		}
+	}
+
+	if len(options.ColumnNames) != 0 {
+		if columnNames != nil && len(options.ColumnNames) != len(columnNames) {
+			return nil, false, fmt.Errorf(
+				"got %d column names, but CSV header row has %d columns",
+				len(options.ColumnNames),
+				len(columnNames),
+			)
+		}
+		columnNames = options.ColumnNames
+	}
+
+	return columnNames, hasHeaderRow, nil
+}
+
+// Ingestion skips the header row unless HasHeaderRow is explicitly false. We don't detect header
+// rows here like in schema deduction, since a misdetected header row would be ingested as data.
+func (options HeaderOptions) SkipHeaderRowOnIngestion() bool {
+	return options.HasHeaderRow == nil || *options.HasHeaderRow
+}
+
+// Returns "column_1", "column_2" etc., for files without a header row.
+func GenerateColumnNames(count int) []string {
+	names := make([]string, count)
+	for i := range names {
+		names[i] = fmt.Sprintf("column_%d", i+1)
+	}
+	return names
+}
+
+func (options HeaderOptions) Validate() error {
+	for _, columnName := range options.ColumnNames {
+		if columnName == "" {
+			return errors.New("column names cannot be blank")
+		}
+	}
+	return nil
+}
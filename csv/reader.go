@@ -1,6 +1,7 @@
 package csv
 
 import (
+	"bufio"
 	"encoding/csv"
 	"errors"
 	"io"
@@ -9,18 +10,101 @@ import (
 )
 
 type Reader struct {
-	inner      *csv.Reader
+	inner *csv.Reader
+	// Nil for readers created with NewStreamReader.
 	file       io.ReadSeeker
+	dialect    Dialect
 	currentRow int
 }
 
-func NewReader(csvFile io.ReadSeeker, skipHeaderRow bool) (*Reader, error) {
-	delimiter, err := DeduceFieldDelimiter(csvFile, 20, DefaultDelimitersToCheck)
+// Creates a reader for the given file, transcoding it to UTF-8 from the given encoding. If encoding
+// is 0, it is detected with DetectEncoding.
+func NewReader(csvFile io.ReadSeeker, skipHeaderRow bool, encoding Encoding) (*Reader, error) {
+	if encoding != 0 && !encoding.IsValid() {
+		return nil, errors.New("invalid CSV file encoding")
+	}
+
+	// Detects the encoding even if it was given, to skip its byte order mark if there is one
+	detectedEncoding, bomLength, err := DetectEncoding(csvFile)
+	if err != nil {
+		return nil, wrap.Error(err, "failed to detect CSV file encoding")
+	}
+	if encoding == 0 {
+		encoding = detectedEncoding
+	} else if encoding != detectedEncoding {
+		bomLength = 0
+	}
+	csvFile = newDecodingReader(csvFile, encoding, bomLength)
+
+	dialect, err := DeduceDialect(csvFile, dialectDeductionRowCount, DefaultDelimitersToCheck)
+	if err != nil {
+		return nil, wrap.Error(err, "failed to deduce CSV dialect")
+	}
+
+	reader := &Reader{
+		inner:      newInnerReader(csvFile, dialect),
+		file:       csvFile,
+		dialect:    dialect,
+		currentRow: 0,
+	}
+
+	if skipHeaderRow {
+		if _, err := reader.ReadHeaderRow(); err != nil {
+			return nil, wrap.Error(err, "failed to skip CSV header row")
+		}
+	}
+
+	return reader, nil
+}
+
+// Creates a reader for CSV data that can only be read once, such as an HTTP request body. Only the
+// start of the stream is buffered, for detecting its encoding and deducing its dialect, so large
+// files can be read with bounded memory. Since the stream can't be read again, ResetReadPosition
+// is not supported.
+func NewStreamReader(stream io.Reader, skipHeaderRow bool, encoding Encoding) (*Reader, error) {
+	if encoding != 0 && !encoding.IsValid() {
+		return nil, errors.New("invalid CSV file encoding")
+	}
+
+	// Peeking at the buffered stream lets us look at its start without consuming it
+	buffered := bufio.NewReaderSize(stream, encodingDetectionPrefixSize)
+	prefix, isTruncated, err := peekPrefix(buffered, encodingDetectionPrefixSize)
 	if err != nil {
 		return nil, err
 	}
 
-	reader := &Reader{inner: newInnerReader(csvFile, delimiter), file: csvFile, currentRow: 0}
+	detectedEncoding, bomLength := detectEncodingFromPrefix(prefix, isTruncated)
+	if encoding == 0 {
+		encoding = detectedEncoding
+	} else if encoding != detectedEncoding {
+		bomLength = 0
+	}
+	if _, err := buffered.Discard(bomLength); err != nil {
+		return nil, wrap.Error(err, "failed to skip byte order mark in CSV stream")
+	}
+
+	// The dialect is deduced from decoded text, so we need a second buffer after decoding
+	decoded := bufio.NewReaderSize(
+		newStreamDecodingReader(buffered, encoding),
+		dialectDeductionPrefixSize,
+	)
+	prefix, isTruncated, err = peekPrefix(decoded, dialectDeductionPrefixSize)
+	if err != nil {
+		return nil, err
+	}
+	dialect := deduceDialectFromPrefix(
+		prefix,
+		isTruncated,
+		dialectDeductionRowCount,
+		DefaultDelimitersToCheck,
+	)
+
+	reader := &Reader{
+		inner:      newInnerReader(decoded, dialect),
+		file:       nil,
+		dialect:    dialect,
+		currentRow: 0,
+	}
 
 	if skipHeaderRow {
 		if _, err := reader.ReadHeaderRow(); err != nil {
@@ -31,11 +115,16 @@ func NewReader(csvFile io.ReadSeeker, skipHeaderRow bool) (*Reader, error) {
 	return reader, nil
 }
 
-func newInnerReader(csvFile io.ReadSeeker, delimiter rune) *csv.Reader {
-	reader := csv.NewReader(csvFile)
-	reader.ReuseRecord = true
-	reader.Comma = delimiter
-	return reader
+func peekPrefix(stream *bufio.Reader, prefixSize int) (prefix []byte, isTruncated bool, err error) {
+	prefix, err = stream.Peek(prefixSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, false, wrap.Error(err, "failed to read start of CSV stream")
+	}
+	return prefix, err == nil, nil
+}
+
+func (reader *Reader) Dialect() Dialect {
+	return reader.dialect
 }
 
 // Implements db.DataSource
@@ -51,6 +140,10 @@ func (reader *Reader) ReadRow() (row []string, rowNumber int, done bool, err err
 		}
 	}
 
+	if reader.dialect.Quote == '\'' {
+		swapQuotes(row)
+	}
+
 	return row, reader.currentRow, false, nil
 }
 
@@ -69,12 +162,16 @@ func (reader *Reader) ReadHeaderRow() (row []string, err error) {
 }
 
 func (reader *Reader) ResetReadPosition(skipHeaderRow bool) error {
+	if reader.file == nil {
+		return errors.New("CSV readers created from streams can't be reset")
+	}
+
 	if _, err := reader.file.Seek(0, io.SeekStart); err != nil {
 		return err
 	}
 
 	reader.currentRow = 0
-	reader.inner = newInnerReader(reader.file, reader.inner.Comma)
+	reader.inner = newInnerReader(reader.file, reader.dialect)
 
 	if skipHeaderRow {
 		if _, err := reader.ReadHeaderRow(); err != nil {
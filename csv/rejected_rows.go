@@ -0,0 +1,36 @@
+package csv
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"hermannm.dev/analysis/db"
+	"hermannm.dev/wrap"
+)
+
+var rejectedRowsHeader = []string{"row_number", "column_name", "value", "reason"}
+
+// Writes the given rejected rows from ingestion as CSV with a header row, so they can be downloaded
+// and fixed by users.
+func WriteRejectedRows(output io.Writer, rejectedRows []db.RejectedRow) error {
+	writer := csv.NewWriter(output)
+
+	if err := writer.Write(rejectedRowsHeader); err != nil {
+		return wrap.Error(err, "failed to write CSV header row")
+	}
+
+	for _, rejectedRow := range rejectedRows {
+		if err := writer.Write([]string{
+			strconv.Itoa(rejectedRow.RowNumber),
+			rejectedRow.ColumnName,
+			rejectedRow.Value,
+			rejectedRow.Reason,
+		}); err != nil {
+			return wrap.Errorf(err, "failed to write rejected row %d", rejectedRow.RowNumber)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,121 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+
+	"hermannm.dev/enumnames"
+)
+
+// Which rows of a CSV file to check when deducing its table schema.
+type SamplingStrategy int8
+
+const (
+	// Checks the first SampleSize rows. Fastest, but misses type conflicts further down the file.
+	SampleFirstRows SamplingStrategy = iota + 1
+	// Checks SampleSize rows picked uniformly at random from the whole file. Reads the whole file,
+	// but only keeps the sampled rows in memory.
+	SampleReservoir
+	// Checks every row in the file. Rows are checked one by one as they are read, so memory use
+	// does not grow with the file size.
+	SampleFullScan
+)
+
+var samplingStrategyMap = enumnames.NewMap(map[SamplingStrategy]string{
+	SampleFirstRows: "FIRST_ROWS",
+	SampleReservoir: "RESERVOIR",
+	SampleFullScan:  "FULL_SCAN",
+})
+
+func (strategy SamplingStrategy) IsValid() bool {
+	return samplingStrategyMap.ContainsKey(strategy)
+}
+
+func (strategy SamplingStrategy) String() string {
+	return samplingStrategyMap.GetNameOrFallback(strategy, "INVALID_SAMPLING_STRATEGY")
+}
+
+func (strategy SamplingStrategy) MarshalJSON() ([]byte, error) {
+	return samplingStrategyMap.MarshalToNameJSON(strategy)
+}
+
+func (strategy *SamplingStrategy) UnmarshalJSON(bytes []byte) error {
+	return samplingStrategyMap.UnmarshalFromNameJSON(bytes, strategy)
+}
+
+type SamplingOptions struct {
+	// If 0, SampleFirstRows is used.
+	Strategy SamplingStrategy `json:"strategy,omitempty"`
+	// Number of rows to check. Ignored for SampleFullScan. If 0, DefaultSampleSize is used.
+	SampleSize int `json:"sampleSize,omitempty"`
+}
+
+const (
+	DefaultSampleSize = 100
+	// Bounds the memory used by reservoir sampling, which keeps all sampled rows in memory.
+	MaxSampleSize = 100000
+)
+
+func (options SamplingOptions) Validate() error {
+	if options.Strategy != 0 && !options.Strategy.IsValid() {
+		return errors.New("invalid sampling strategy")
+	}
+	if options.SampleSize < 0 || options.SampleSize > MaxSampleSize {
+		return fmt.Errorf("sample size must be between 1 and %d", MaxSampleSize)
+	}
+	return nil
+}
+
+func (options SamplingOptions) strategy() SamplingStrategy {
+	if options.Strategy == 0 {
+		return SampleFirstRows
+	}
+	return options.Strategy
+}
+
+func (options SamplingOptions) sampleSize() int {
+	if options.SampleSize == 0 {
+		return DefaultSampleSize
+	}
+	return options.SampleSize
+}
+
+type sampledRow struct {
+	row       []string
+	rowNumber int
+}
+
+// Picks sampleSize rows uniformly at random from the remaining rows in the reader, using reservoir
+// sampling (https://en.wikipedia.org/wiki/Reservoir_sampling#Simple:_Algorithm_R). Returns the rows
+// in the order they appear in the file.
+func (reader *Reader) sampleReservoir(sampleSize int) ([]sampledRow, error) {
+	reservoir := make([]sampledRow, 0, sampleSize)
+
+	for seenRows := 0; ; seenRows++ {
+		row, rowNumber, done, err := reader.ReadRow()
+		if done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var index int
+		if seenRows < sampleSize {
+			reservoir = append(reservoir, sampledRow{})
+			index = seenRows
+		} else if index = rand.Intn(seenRows + 1); index >= sampleSize {
+			continue
+		}
+
+		// The CSV reader reuses its row slice, so we copy the rows that we keep
+		reservoir[index] = sampledRow{row: slices.Clone(row), rowNumber: rowNumber}
+	}
+
+	slices.SortFunc(reservoir, func(row1 sampledRow, row2 sampledRow) int {
+		return row1.rowNumber - row2.rowNumber
+	})
+	return reservoir, nil
+}
@@ -5,41 +5,78 @@ import (
 	"hermannm.dev/wrap"
 )
 
-func (reader *Reader) DeduceTableSchema(maxRowsToCheck int) (schema db.TableSchema, err error) {
-	columnNames, err := reader.ReadHeaderRow()
+// Deduces a table schema from the CSV file. Also returns whether the file has a header row, as
+// given in the header options or detected from the file. Ingestion assumes a header row unless
+// told otherwise (see HeaderOptions.SkipHeaderRowOnIngestion), so callers should pass this on.
+func (reader *Reader) DeduceTableSchema(
+	headerOptions HeaderOptions,
+	samplingOptions SamplingOptions,
+	parseOptions db.ParseOptions,
+) (
+	schema db.TableSchema,
+	report db.SchemaDeductionReport,
+	hasHeaderRow bool,
+	err error,
+) {
+	headers, hasHeaderRow, err := reader.readColumnNames(headerOptions, parseOptions)
 	if err != nil {
-		return db.TableSchema{}, wrap.Error(
-			err,
-			"failed to read CSV column names from header row",
-		)
+		return db.TableSchema{}, db.SchemaDeductionReport{}, false, err
 	}
 
-	schema = db.NewTableSchema(columnNames)
+	deducer := db.NewSchemaDeducer(headers, parseOptions)
 
-	for {
-		row, rowNumber, done, err := reader.ReadRow()
-		if done || rowNumber > maxRowsToCheck {
-			break
-		}
-		if err != nil {
-			return db.TableSchema{}, wrap.Errorf(err, "failed to read CSV file")
+	deduceFromRow := func(row []string, rowNumber int) error {
+		if err := deducer.DeduceDataTypesFromRow(row, rowNumber); err != nil {
+			return wrap.Errorf(err, "failed to parse CSV data types from row %d", rowNumber)
 		}
+		return nil
+	}
 
-		if err := schema.DeduceDataTypesFromRow(row); err != nil {
-			return db.TableSchema{}, wrap.Errorf(
+	switch samplingOptions.strategy() {
+	case SampleReservoir:
+		sample, err := reader.sampleReservoir(samplingOptions.sampleSize())
+		if err != nil {
+			return db.TableSchema{}, db.SchemaDeductionReport{}, false, wrap.Error(
 				err,
-				"failed to parse CSV data types from row %d",
-				rowNumber,
+				"failed to read CSV file",
 			)
 		}
+
+		for _, sampledRow := range sample {
+			if err := deduceFromRow(sampledRow.row, sampledRow.rowNumber); err != nil {
+				return db.TableSchema{}, db.SchemaDeductionReport{}, false, err
+			}
+		}
+	default:
+		// If there is a header row, it has been read, so we offset by it
+		maxRowNumber := reader.currentRow + samplingOptions.sampleSize()
+		fullScan := samplingOptions.strategy() == SampleFullScan
+
+		for {
+			row, rowNumber, done, err := reader.ReadRow()
+			if done || (!fullScan && rowNumber > maxRowNumber) {
+				break
+			}
+			if err != nil {
+				return db.TableSchema{}, db.SchemaDeductionReport{}, false, wrap.Error(
+					err,
+					"failed to read CSV file",
+				)
+			}
+
+			if err := deduceFromRow(row, rowNumber); err != nil {
+				return db.TableSchema{}, db.SchemaDeductionReport{}, false, err
+			}
+		}
 	}
 
+	schema, report = deducer.Schema()
 	if errs := schema.ValidateColumns(); len(errs) > 0 {
-		return db.TableSchema{}, wrap.Errors(
+		return db.TableSchema{}, db.SchemaDeductionReport{}, false, wrap.Errors(
 			"failed to deduce data types for all given CSV columns",
 			errs...,
 		)
 	}
 
-	return schema, nil
+	return schema, report, hasHeaderRow, nil
 }
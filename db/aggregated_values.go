@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"slices"
 
+	"github.com/shopspring/decimal"
 	"hermannm.dev/wrap"
 )
 
@@ -20,12 +21,20 @@ type aggregatedValues[T interface{ int64 | float64 }] struct {
 	values []T
 }
 
+// Implements AggregatedValues for DECIMAL, summing with decimal arithmetic to avoid the rounding
+// errors of floating-point.
+type decimalAggregatedValues struct {
+	values []decimal.Decimal
+}
+
 func NewAggregatedValues(dataType DataType, capacity int) (AggregatedValues, error) {
 	switch dataType {
 	case DataTypeInt:
 		return &aggregatedValues[int64]{make([]int64, 0, capacity)}, nil
 	case DataTypeFloat:
 		return &aggregatedValues[float64]{make([]float64, 0, capacity)}, nil
+	case DataTypeDecimal:
+		return &decimalAggregatedValues{make([]decimal.Decimal, 0, capacity)}, nil
 	default:
 		return nil, fmt.Errorf("invalid data type %v", dataType)
 	}
@@ -93,3 +102,63 @@ func (list aggregatedValues[T]) MarshalJSON() ([]byte, error) {
 func (list *aggregatedValues[T]) UnmarshalJSON(bytes []byte) error {
 	return json.Unmarshal(bytes, &list.values)
 }
+
+func (list *decimalAggregatedValues) Insert(index int, value any) (ok bool) {
+	if value, ok := value.(decimal.Decimal); ok {
+		if index >= len(list.values) {
+			list.AddZeroesUpToLength(index + 1)
+		}
+
+		list.values[index] = value
+		return true
+	} else {
+		return false
+	}
+}
+
+func (list *decimalAggregatedValues) InsertZero(index int) {
+	if index <= len(list.values) {
+		list.values = slices.Insert(list.values, index, decimal.Zero)
+	} else {
+		list.AddZeroesUpToLength(index + 1)
+	}
+}
+
+func (list *decimalAggregatedValues) AddZeroesUpToLength(length int) {
+	// The zero value of decimal.Decimal is 0
+	zeroes := make([]decimal.Decimal, length-len(list.values))
+	list.values = append(list.values, zeroes...)
+}
+
+func (list *decimalAggregatedValues) Total(dataType DataType) (DBValue, error) {
+	total := decimal.Sum(decimal.Zero, list.values...)
+
+	totalValue, err := NewDBValue(dataType)
+	if err != nil {
+		return nil, wrap.Error(err, "failed to create value for total of aggregated values")
+	}
+
+	if ok := totalValue.Set(total); !ok {
+		return nil, fmt.Errorf(
+			"failed to set aggregated values total '%v' with data type '%v'",
+			total,
+			dataType,
+		)
+	}
+
+	return totalValue, nil
+}
+
+func (list *decimalAggregatedValues) Truncate(maxLength int) {
+	if len(list.values) > maxLength {
+		list.values = list.values[:maxLength]
+	}
+}
+
+func (list decimalAggregatedValues) MarshalJSON() ([]byte, error) {
+	return json.Marshal(list.values)
+}
+
+func (list *decimalAggregatedValues) UnmarshalJSON(bytes []byte) error {
+	return json.Unmarshal(bytes, &list.values)
+}
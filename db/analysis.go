@@ -28,8 +28,14 @@ type Split struct {
 	IntegerInterval int `json:"integerInterval,omitempty"`
 	// May only be present if DataType is FLOAT.
 	FloatInterval float64 `json:"floatInterval,omitempty"`
-	// May only be present if DataType is DATETIME.
+	// May only be present if DataType is DATETIME or DATE.
 	DateInterval DateInterval `json:"dateInterval,omitempty"`
+	// Splits GEO_POINT values by geohash grid cells of this precision (1-12), with the geohashes
+	// as split values. If DataType is GEO_POINT, either this or GeotileZoom must be present.
+	GeohashPrecision int `json:"geohashPrecision,omitempty"`
+	// Splits GEO_POINT values by map tiles at this zoom level (1-29), with "zoom/x/y" tile keys as
+	// split values. If DataType is GEO_POINT, either this or GeohashPrecision must be present.
+	GeotileZoom int `json:"geotileZoom,omitempty"`
 }
 
 type AnalysisResult struct {
@@ -69,12 +75,12 @@ func NewAnalysisQueryResult(analysis AnalysisQuery) AnalysisResult {
 }
 
 func (analysisResult *AnalysisResult) NewResultHandle() (handle ResultHandle, err error) {
-	handle.Column, err = NewDBValue(analysisResult.ColumnsMeta.DataType)
+	handle.Column, err = NewDBValue(analysisResult.ColumnsMeta.DataType.ValueDataType())
 	if err != nil {
 		return ResultHandle{}, wrap.Error(err, "failed to initialize column value")
 	}
 
-	handle.Row, err = NewDBValue(analysisResult.RowsMeta.DataType)
+	handle.Row, err = NewDBValue(analysisResult.RowsMeta.DataType.ValueDataType())
 	if err != nil {
 		return ResultHandle{}, wrap.Error(err, "failed to initialize row value")
 	}
@@ -121,7 +127,7 @@ func (analysisResult *AnalysisResult) GetOrCreateRowResult(
 		}
 	}
 
-	rowValue, err := NewDBValue(analysisResult.RowsMeta.DataType)
+	rowValue, err := NewDBValue(analysisResult.RowsMeta.DataType.ValueDataType())
 	if err != nil {
 		return RowResult{}, wrap.Error(err, "failed to initialize row field value")
 	}
@@ -160,7 +166,7 @@ func (analysisResult *AnalysisResult) InitializeColumnResult(
 	}
 
 	// If the column is not added previously, we parse the column value.
-	columnValue, err := NewDBValue(analysisResult.ColumnsMeta.DataType)
+	columnValue, err := NewDBValue(analysisResult.ColumnsMeta.DataType.ValueDataType())
 	if err != nil {
 		return 0, wrap.Error(err, "failed to initialize column field value")
 	}
@@ -58,13 +58,13 @@ func translateAnalysisQuery(analysis db.AnalysisQuery, table string) (*QueryBuil
 	query.WriteString("FROM ")
 	query.AddIdentifier(table)
 
-	// WHERE clause to get the top N rows by aggregation totals
+	// WHERE clause to get the top N rows by aggregation totals. Uses the same split expression as
+	// above, so that the subquery's values match row_split for interval and TAGS splits.
 	query.WriteString(" WHERE row_split IN (SELECT ")
-	query.AddIdentifier(analysis.RowSplit.FieldName)
-	query.WriteString(" FROM ")
+	query.WriteSplit(analysis.RowSplit) // Error checked above
+	query.WriteString(" AS top_row_split FROM ")
 	query.AddIdentifier(table)
-	query.WriteString(" GROUP BY ")
-	query.AddIdentifier(analysis.RowSplit.FieldName)
+	query.WriteString(" GROUP BY top_row_split")
 	query.WriteString(" ORDER BY ")
 	query.WriteAggregation(analysis.Aggregation) // Error checked above
 	query.WriteString(" DESC")
@@ -1,6 +1,8 @@
 package clickhouse
 
 import (
+	"fmt"
+
 	"hermannm.dev/analysis/db"
 	"hermannm.dev/enumnames"
 )
@@ -11,7 +13,11 @@ const (
 	typeInt64      = "Int64"
 	typeFloat64    = "Float64"
 	typeDateTime   = "DateTime64(3)"
+	typeDate       = "Date32"
 	typeUUID       = "UUID"
+	typeBool       = "Bool"
+	typeTags       = "Array(String)"
+	typePoint      = "Point"
 	typeString     = "String"
 	typeIdentifier = "Identifier"
 )
@@ -22,8 +28,57 @@ var clickhouseDataTypes = enumnames.NewMap(map[db.DataType]string{
 	db.DataTypeDateTime: typeDateTime,
 	db.DataTypeUUID:     typeUUID,
 	db.DataTypeText:     typeString,
+	db.DataTypeBool:     typeBool,
+	db.DataTypeDate:     typeDate,
 })
 
+// Returns the ClickHouse type for the given column, including its precision/scale for DECIMAL and
+// its nullability for optional columns.
+func clickhouseColumnType(column db.Column) (string, error) {
+	var columnType string
+
+	switch column.DataType {
+	case db.DataTypeDecimal:
+		// https://clickhouse.com/docs/en/sql-reference/data-types/decimal
+		columnType = fmt.Sprintf("Decimal(%d, %d)", column.Precision, column.Scale)
+	case db.DataTypeCategory:
+		// LowCardinality stores values in a dictionary, which speeds up grouping on columns with
+		// few distinct values. Nullable must be inside LowCardinality, not the other way around.
+		// https://clickhouse.com/docs/en/sql-reference/data-types/lowcardinality
+		if column.Optional {
+			return "LowCardinality(Nullable(String))", nil
+		}
+		return "LowCardinality(String)", nil
+	case db.DataTypeTags:
+		// Arrays cannot be Nullable in ClickHouse, so we store empty arrays instead of NULL (see
+		// db.Column.parseTags)
+		return typeTags, nil
+	case db.DataTypeGeoPoint:
+		// Point is a tuple of (longitude, latitude), and tuples cannot be Nullable
+		// https://clickhouse.com/docs/en/sql-reference/data-types/geo#point
+		if column.Optional {
+			return "", fmt.Errorf(
+				"ClickHouse does not support optional %v columns",
+				db.DataTypeGeoPoint,
+			)
+		}
+		return typePoint, nil
+	default:
+		var ok bool
+		columnType, ok = clickhouseDataTypes.GetName(column.DataType)
+		if !ok {
+			return "", fmt.Errorf("invalid data type '%v'", column.DataType)
+		}
+	}
+
+	if column.Optional {
+		// https://clickhouse.com/docs/en/sql-reference/data-types/nullable
+		columnType = "Nullable(" + columnType + ")"
+	}
+
+	return columnType, nil
+}
+
 // See https://clickhouse.com/docs/en/sql-reference/statements/select/order-by
 var clickhouseSortOrders = enumnames.NewMap(map[db.SortOrder]string{
 	db.SortOrderAscending:  "ASC",
@@ -38,3 +93,15 @@ var clickhouseAggregationKinds = enumnames.NewMap(map[db.AggregationKind]string{
 	db.AggregationMax:     "max",
 	db.AggregationCount:   "count",
 })
+
+// See https://clickhouse.com/docs/en/sql-reference/operators
+var clickhouseFilterOperators = enumnames.NewMap(map[db.FilterOperator]string{
+	db.FilterEquals:              "=",
+	db.FilterNotEquals:           "!=",
+	db.FilterLessThan:            "<",
+	db.FilterLessThanOrEquals:    "<=",
+	db.FilterGreaterThan:         ">",
+	db.FilterGreaterThanOrEquals: ">=",
+	db.FilterIsNull:              "IS NULL",
+	db.FilterIsNotNull:           "IS NOT NULL",
+})
@@ -3,9 +3,12 @@ package clickhouse
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"strings"
 
 	"github.com/google/uuid"
 	"hermannm.dev/analysis/db"
+	"hermannm.dev/devlog/log"
 	"hermannm.dev/wrap"
 )
 
@@ -21,16 +24,12 @@ func (clickhouse ClickHouseDB) CreateTable(ctx context.Context, schema db.TableS
 		}
 		query.WriteByte(' ')
 
-		dataType, ok := clickhouseDataTypes.GetName(column.DataType)
-		if !ok {
-			return fmt.Errorf("invalid data type '%v' in column '%s'", column.DataType, column.Name)
+		dataType, err := clickhouseColumnType(column)
+		if err != nil {
+			return wrap.Errorf(err, "failed to get ClickHouse type for column '%s'", column.Name)
 		}
 		query.WriteString(dataType)
 
-		if column.Optional {
-			query.WriteString(" NULL")
-		}
-
 		if i != len(schema.Columns)-1 {
 			query.WriteString(", ")
 		}
@@ -55,14 +54,109 @@ func (clickhouse ClickHouseDB) CreateTable(ctx context.Context, schema db.TableS
 // https://clickhouse.com/docs/en/cloud/bestpractices/bulk-inserts
 const BatchInsertSize = 10000
 
+// Ingests data all-or-nothing, by first inserting it into a staging table with the same structure
+// as the target table, and then attaching the staging table's data to the target table in a single
+// operation. If ingestion fails, the staging table is dropped, leaving the target table untouched.
 func (clickhouse ClickHouseDB) IngestData(
 	ctx context.Context,
 	data db.DataSource,
 	schema db.TableSchema,
-) error {
+	options db.IngestionOptions,
+) (report db.IngestionReport, err error) {
+	stagingTable, err := clickhouse.createStagingTable(ctx, schema.TableName)
+	if err != nil {
+		return db.IngestionReport{}, err
+	}
+	defer func() {
+		// Drops the staging table even if the ingestion context was canceled
+		_, dropErr := clickhouse.DropTable(context.WithoutCancel(ctx), stagingTable)
+		if dropErr == nil {
+			return
+		}
+
+		// If ingestion succeeded, the data has been attached to the target table, so failing here
+		// would make clients retry and duplicate it
+		if err == nil {
+			log.ErrorCause(
+				dropErr,
+				"data was inserted, but failed to drop staging table",
+				slog.String("table", schema.TableName),
+				slog.String("stagingTable", stagingTable),
+			)
+		} else {
+			err = wrap.Errors(
+				"failed to ingest data AND failed to drop staging table afterwards",
+				err,
+				dropErr,
+			)
+		}
+	}()
+
+	report, err = clickhouse.insertBatches(ctx, data, schema, stagingTable, options)
+	if err != nil {
+		return report, err
+	}
+
+	// Attaching an empty partition fails, so we skip it if all rows were rejected
+	if report.InsertedRows == 0 {
+		return report, nil
+	}
+
+	// Tables created by CreateTable have no PARTITION BY clause, so all their data is in a single
+	// partition with the ID 'all'. ATTACH PARTITION FROM copies the data parts of the partition to
+	// the target table atomically.
+	// See https://clickhouse.com/docs/en/sql-reference/statements/alter/partition#attach-partition-from
 	var query QueryBuilder
-	query.WriteString("INSERT INTO ")
+	query.WriteString("ALTER TABLE ")
 	query.AddIdentifier(schema.TableName)
+	query.WriteString(" ATTACH PARTITION ID 'all' FROM ")
+	query.AddIdentifier(stagingTable)
+
+	if err := clickhouse.conn.Exec(query.WithParameters(ctx), query.String()); err != nil {
+		return report, wrap.Errorf(
+			err,
+			"failed to attach data from staging table to table '%s'",
+			schema.TableName,
+		)
+	}
+
+	return report, nil
+}
+
+// Creates an empty table with the same columns and engine as the given table, to insert data into
+// before attaching it to the given table.
+func (clickhouse ClickHouseDB) createStagingTable(
+	ctx context.Context,
+	table string,
+) (stagingTable string, err error) {
+	stagingID := strings.ReplaceAll(uuid.NewString(), "-", "")
+	stagingTable = fmt.Sprintf("%s__staging_%s", table, stagingID)
+
+	var query QueryBuilder
+	query.WriteString("CREATE TABLE ")
+	query.AddIdentifier(stagingTable)
+	query.WriteString(" AS ")
+	query.AddIdentifier(table)
+
+	if err := clickhouse.conn.Exec(query.WithParameters(ctx), query.String()); err != nil {
+		return "", wrap.Errorf(err, "failed to create staging table for table '%s'", table)
+	}
+
+	return stagingTable, nil
+}
+
+func (clickhouse ClickHouseDB) insertBatches(
+	ctx context.Context,
+	data db.DataSource,
+	schema db.TableSchema,
+	table string,
+	options db.IngestionOptions,
+) (db.IngestionReport, error) {
+	var report db.IngestionReport
+
+	var query QueryBuilder
+	query.WriteString("INSERT INTO ")
+	query.AddIdentifier(table)
 	queryString := query.String()
 	ctx = query.WithParameters(ctx)
 
@@ -72,45 +166,53 @@ func (clickhouse ClickHouseDB) IngestData(
 	for !allRowsSent {
 		batch, err := clickhouse.conn.PrepareBatch(ctx, queryString)
 		if err != nil {
-			return wrap.Error(err, "failed to prepare batch data insert")
+			return report, wrap.Error(err, "failed to prepare batch data insert")
 		}
 
-		for i := 0; i < BatchInsertSize; i++ {
+		batchSize := 0
+		for batchSize < BatchInsertSize {
 			rawRow, rowNumber, done, err := data.ReadRow()
 			if done {
 				allRowsSent = true
 				break
 			}
 			if err != nil {
-				return wrap.Error(err, "failed to read row")
+				return report, wrap.Error(err, "failed to read row")
 			}
+			options.Progress.AddRowRead()
 
 			convertedRow := make([]any, 0, fieldsPerRow)
 
 			id, err := uuid.NewUUID()
 			if err != nil {
-				return wrap.Errorf(err, "failed to generate unique ID for row %d", rowNumber)
+				return report, wrap.Errorf(
+					err,
+					"failed to generate unique ID for row %d",
+					rowNumber,
+				)
 			}
 			convertedRow = append(convertedRow, id.String())
 
 			convertedRow, err = schema.ConvertAndAppendRow(convertedRow, rawRow)
 			if err != nil {
-				return wrap.Errorf(
-					err,
-					"failed to convert row %d to data types expected by table schema",
-					rowNumber,
-				)
+				if err := report.RejectRow(err, rowNumber, options); err != nil {
+					return report, err
+				}
+				continue
 			}
 
 			if err := batch.Append(convertedRow...); err != nil {
-				return wrap.Errorf(err, "failed to add row %d to batch insert", rowNumber)
+				return report, wrap.Errorf(err, "failed to add row %d to batch insert", rowNumber)
 			}
+			batchSize++
 		}
 
 		if err := batch.Send(); err != nil {
-			return wrap.Error(err, "failed to send batch insert")
+			return report, wrap.Error(err, "failed to send batch insert")
 		}
+		report.InsertedRows += batchSize
+		options.Progress.AddRowsInserted(batchSize)
 	}
 
-	return nil
+	return report, nil
 }
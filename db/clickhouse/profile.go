@@ -0,0 +1,271 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"hermannm.dev/analysis/db"
+	"hermannm.dev/wrap"
+)
+
+func (clickhouse ClickHouseDB) ProfileTable(
+	ctx context.Context,
+	schema db.TableSchema,
+) (db.TableProfile, error) {
+	profile := db.TableProfile{
+		TableName: schema.TableName,
+		Columns:   make([]db.ColumnProfile, len(schema.Columns)),
+	}
+
+	query, scanTargets, err := translateProfileQuery(schema, &profile)
+	if err != nil {
+		return db.TableProfile{}, wrap.Error(err, "failed to create profiling query")
+	}
+
+	result := clickhouse.conn.QueryRow(query.WithParameters(ctx), query.String())
+	if err := result.Err(); err != nil {
+		return db.TableProfile{}, wrap.Error(err, "ClickHouse profiling query failed")
+	}
+	if err := result.Scan(scanTargets.all...); err != nil {
+		return db.TableProfile{}, wrap.Error(err, "failed to scan ClickHouse profiling result")
+	}
+
+	if err := scanTargets.setProfileValues(&profile, schema); err != nil {
+		return db.TableProfile{}, wrap.Error(err, "failed to parse ClickHouse profiling result")
+	}
+
+	return profile, nil
+}
+
+type profileScanTargets struct {
+	all      []any
+	rowCount uint64
+	columns  []columnProfileScanTargets
+}
+
+type columnProfileScanTargets struct {
+	nullCount     uint64
+	distinctCount uint64
+	// Pointers-to-pointers from newNullableScanTarget, nil for columns without min/max.
+	min any
+	max any
+	// Pointer, nil for columns without mean.
+	mean *float64
+	// Pointer to slice of the column's DBValue type (see newTopValuesScanTarget), nil for columns
+	// without distinct values.
+	topValues      any
+	topValueCounts []uint64
+}
+
+func translateProfileQuery(
+	schema db.TableSchema,
+	profile *db.TableProfile,
+) (*QueryBuilder, *profileScanTargets, error) {
+	scanTargets := &profileScanTargets{
+		columns: make([]columnProfileScanTargets, len(schema.Columns)),
+	}
+
+	var query QueryBuilder
+	query.WriteString("SELECT count()")
+	scanTargets.all = append(scanTargets.all, &scanTargets.rowCount)
+
+	for i, column := range schema.Columns {
+		profile.Columns[i] = db.NewColumnProfile(column)
+		columnTargets := &scanTargets.columns[i]
+
+		// TAGS columns store empty arrays instead of NULL (see clickhouseColumnType)
+		if column.DataType == db.DataTypeTags {
+			query.WriteString(", countIf(empty(")
+		} else {
+			query.WriteString(", countIf(isNull(")
+		}
+		query.AddIdentifier(column.Name)
+		query.WriteString("))")
+		scanTargets.all = append(scanTargets.all, &columnTargets.nullCount)
+
+		if db.HasDistinctValues(column.DataType) {
+			// uniq is approximate, but much faster than uniqExact on large tables
+			// https://clickhouse.com/docs/en/sql-reference/aggregate-functions/reference/uniq
+			if column.DataType == db.DataTypeTags {
+				// Counts distinct tags rather than distinct arrays, using the -Array combinator
+				// https://clickhouse.com/docs/en/sql-reference/aggregate-functions/combinators#-array
+				query.WriteString(", uniqArray(")
+			} else {
+				query.WriteString(", uniq(")
+			}
+			query.AddIdentifier(column.Name)
+			query.WriteByte(')')
+			scanTargets.all = append(scanTargets.all, &columnTargets.distinctCount)
+
+			topValue, err := db.NewDBValue(column.DataType.ValueDataType())
+			if err != nil {
+				return nil, nil, wrap.Errorf(err, "invalid data type in column '%s'", column.Name)
+			}
+
+			// UUIDs can only be scanned into strings one by one, not in arrays
+			if column.DataType == db.DataTypeUUID {
+				query.WriteString(", arrayMap(t -> toString(t.1), ")
+			} else {
+				query.WriteString(", arrayMap(t -> t.1, ")
+			}
+			query.writeTopValues(column)
+			query.WriteString("), arrayMap(t -> t.2, ")
+			query.writeTopValues(column)
+			query.WriteByte(')')
+
+			columnTargets.topValues = newTopValuesScanTarget(topValue)
+			scanTargets.all = append(
+				scanTargets.all,
+				columnTargets.topValues,
+				&columnTargets.topValueCounts,
+			)
+		}
+
+		if db.HasMinMaxProfile(column.DataType) {
+			min, err := db.NewDBValue(column.DataType)
+			if err != nil {
+				return nil, nil, wrap.Errorf(err, "invalid data type in column '%s'", column.Name)
+			}
+			max, _ := db.NewDBValue(column.DataType) // Same data type as above, so no error
+			profile.Columns[i].Min = min
+			profile.Columns[i].Max = max
+
+			// minOrNull/maxOrNull return NULL if there are no non-NULL values, instead of the
+			// type's default value
+			// https://clickhouse.com/docs/en/sql-reference/aggregate-functions/combinators#-ornull
+			query.WriteString(", minOrNull(")
+			query.AddIdentifier(column.Name)
+			query.WriteString("), maxOrNull(")
+			query.AddIdentifier(column.Name)
+			query.WriteByte(')')
+
+			columnTargets.min = newNullableScanTarget(min)
+			columnTargets.max = newNullableScanTarget(max)
+			scanTargets.all = append(scanTargets.all, columnTargets.min, columnTargets.max)
+		}
+
+		if db.HasMeanProfile(column.DataType) {
+			// Converts to Float64 first, as the average of a Decimal column is itself a Decimal
+			query.WriteString(", avgOrNull(toFloat64(")
+			query.AddIdentifier(column.Name)
+			query.WriteString("))")
+
+			columnTargets.mean = new(float64)
+			scanTargets.all = append(scanTargets.all, &columnTargets.mean)
+		}
+	}
+
+	query.WriteString(" FROM ")
+	query.AddIdentifier(schema.TableName)
+
+	return &query, scanTargets, nil
+}
+
+// Writes a topK aggregation of the most frequent values in the given column, so that we get them in
+// the same pass as the rest of the profile instead of a separate GROUP BY query per column. With
+// 'counts', topK returns tuples of (value, count, error). topK is approximate, so counts may be
+// overestimated for columns with many distinct values.
+// https://clickhouse.com/docs/en/sql-reference/aggregate-functions/reference/topk
+//
+// The aggregation is written twice in the profiling query, to get values and counts as separate
+// arrays, but ClickHouse only computes identical aggregations once.
+func (query *QueryBuilder) writeTopValues(column db.Column) {
+	limit := strconv.Itoa(db.ProfileTopValuesLimit)
+
+	if column.DataType == db.DataTypeTags {
+		// Counts individual tags rather than arrays, using the -Array combinator
+		query.WriteString("topKArray(" + limit + ", 3, 'counts')(")
+		query.AddIdentifier(column.Name)
+		query.WriteByte(')')
+	} else {
+		// Skips NULLs with the -If combinator, and removes Nullable from the values so they don't
+		// have to be scanned as pointers
+		query.WriteString("topKIf(" + limit + ", 3, 'counts')(assumeNotNull(")
+		query.AddIdentifier(column.Name)
+		query.WriteString("), isNotNull(")
+		query.AddIdentifier(column.Name)
+		query.WriteString("))")
+	}
+}
+
+// Returns a pointer to a slice of the type pointed to by value.Pointer(), for scanning arrays.
+func newTopValuesScanTarget(value db.DBValue) any {
+	return reflect.New(reflect.SliceOf(reflect.TypeOf(value.Pointer()).Elem())).Interface()
+}
+
+func (scanTargets *profileScanTargets) setProfileValues(
+	profile *db.TableProfile,
+	schema db.TableSchema,
+) error {
+	profile.RowCount = int64(scanTargets.rowCount)
+
+	for i, columnTargets := range scanTargets.columns {
+		columnProfile := &profile.Columns[i]
+		columnProfile.NullCount = int64(columnTargets.nullCount)
+		columnProfile.DistinctCount = int64(columnTargets.distinctCount)
+
+		if columnTargets.min != nil {
+			isNull, err := setFromNullableScanTarget(columnProfile.Min, columnTargets.min)
+			if err != nil {
+				return wrap.Errorf(err, "invalid min value for column '%s'", columnProfile.Name)
+			}
+			if isNull {
+				columnProfile.Min = nil
+			}
+
+			isNull, err = setFromNullableScanTarget(columnProfile.Max, columnTargets.max)
+			if err != nil {
+				return wrap.Errorf(err, "invalid max value for column '%s'", columnProfile.Name)
+			}
+			if isNull {
+				columnProfile.Max = nil
+			}
+		}
+
+		columnProfile.Mean = columnTargets.mean
+
+		if columnTargets.topValues != nil {
+			topValues, err := parseTopValues(
+				columnTargets.topValues,
+				columnTargets.topValueCounts,
+				schema.Columns[i].DataType.ValueDataType(),
+			)
+			if err != nil {
+				return wrap.Errorf(err, "invalid top values for column '%s'", columnProfile.Name)
+			}
+			columnProfile.TopValues = topValues
+		}
+	}
+
+	return nil
+}
+
+func parseTopValues(
+	valuesScanTarget any,
+	counts []uint64,
+	valueDataType db.DataType,
+) ([]db.DistinctValue, error) {
+	values := reflect.ValueOf(valuesScanTarget).Elem()
+	if values.Len() != len(counts) {
+		return nil, fmt.Errorf("got %d values, but %d counts", values.Len(), len(counts))
+	}
+
+	topValues := make([]db.DistinctValue, values.Len())
+	for i := range topValues {
+		value, err := db.NewDBValue(valueDataType)
+		if err != nil {
+			return nil, err
+		}
+
+		scannedValue := values.Index(i).Interface()
+		if ok := value.Set(scannedValue); !ok {
+			return nil, fmt.Errorf("failed to set scanned value '%v'", scannedValue)
+		}
+
+		topValues[i] = db.DistinctValue{Value: value, Count: int64(counts[i])}
+	}
+
+	return topValues, nil
+}
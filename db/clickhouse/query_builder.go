@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/shopspring/decimal"
 	"hermannm.dev/analysis/db"
 )
 
@@ -53,6 +55,45 @@ func (query *QueryBuilder) AddStringParameter(s string) {
 	query.AddParameter(s, typeString)
 }
 
+// Adds the given value as a query parameter of the ClickHouse type matching the given data type.
+func (query *QueryBuilder) AddValueParameter(value db.DBValue, dataType db.DataType) error {
+	switch value := value.Value().(type) {
+	case int64:
+		query.AddParameter(strconv.FormatInt(value, 10), typeInt64)
+	case float64:
+		query.AddFloatParameter(value)
+	case bool:
+		query.AddParameter(strconv.FormatBool(value), typeBool)
+	case decimal.Decimal:
+		// Uses the value's own scale, so the parameter is not rounded before comparing. ClickHouse
+		// can compare decimals of different scales.
+		scale := max(-value.Exponent(), 0)
+		query.AddParameter(value.String(), fmt.Sprintf("Decimal256(%d)", scale))
+	case time.Time:
+		if dataType == db.DataTypeDate {
+			query.AddParameter(value.Format(db.DateFormat), typeDate)
+			break
+		}
+
+		// We insert datetimes as Unix milliseconds (see db.TableSchema.ConvertAndAppendRow), so we
+		// compare against them in the same format, to avoid time zone mismatches
+		// https://clickhouse.com/docs/en/sql-reference/functions/type-conversion-functions#fromunixtimestamp64milli
+		query.WriteString("fromUnixTimestamp64Milli(")
+		query.AddParameter(strconv.FormatInt(value.UnixMilli(), 10), typeInt64)
+		query.WriteByte(')')
+	case string:
+		if dataType == db.DataTypeUUID {
+			query.AddParameter(value, typeUUID)
+		} else {
+			query.AddStringParameter(value)
+		}
+	default:
+		return fmt.Errorf("unsupported value '%v' for data type %v", value, dataType)
+	}
+
+	return nil
+}
+
 func (query *QueryBuilder) AddIdentifier(identifier string) {
 	query.AddParameter(identifier, typeIdentifier)
 }
@@ -109,7 +150,40 @@ func (query *QueryBuilder) WriteAggregation(aggregation db.Aggregation) error {
 	return nil
 }
 
+func (query *QueryBuilder) WriteFilter(filter db.Filter, dataType db.DataType) error {
+	operator, ok := clickhouseFilterOperators.GetName(filter.Operator)
+	if !ok {
+		return fmt.Errorf("unrecognized filter operator '%v'", filter.Operator)
+	}
+
+	value, err := filter.ParseValue(dataType)
+	if err != nil {
+		return err
+	}
+
+	if dataType == db.DataTypeTags {
+		return query.writeTagsFilter(filter, value)
+	}
+
+	query.AddIdentifier(filter.FieldName)
+	query.WriteByte(' ')
+	query.WriteString(operator)
+
+	if value != nil {
+		query.WriteByte(' ')
+		if err := query.AddValueParameter(value, dataType); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (query *QueryBuilder) WriteSplit(split db.Split) error {
+	if err := split.ValidateGeoGrid(); err != nil {
+		return err
+	}
+
 	switch split.DataType {
 	case db.DataTypeInt:
 		if split.IntegerInterval != 0 {
@@ -135,7 +209,7 @@ func (query *QueryBuilder) WriteSplit(split db.Split) error {
 			query.WriteByte(')')
 			return nil
 		}
-	case db.DataTypeDateTime:
+	case db.DataTypeDateTime, db.DataTypeDate:
 		if !split.DateInterval.IsNone() {
 			// https://clickhouse.com/docs/en/sql-reference/functions/date-time-functions#tostartofyear
 			switch split.DateInterval {
@@ -164,9 +238,121 @@ func (query *QueryBuilder) WriteSplit(split db.Split) error {
 			}
 			return nil
 		}
+	case db.DataTypeGeoPoint:
+		// Validated above to have either geohash precision or geotile zoom
+		if split.GeohashPrecision != 0 {
+			query.writeGeohash(split.FieldName, split.GeohashPrecision)
+		} else {
+			query.writeGeotileKey(split.FieldName, split.GeotileZoom)
+		}
+		return nil
 	}
 
 	// If we get here, no interval was specified
-	query.AddIdentifier(split.FieldName)
+	query.WriteColumnValues(split.FieldName, split.DataType)
+	return nil
+}
+
+// Writes the geohash of the given Point column, which is a tuple of (longitude, latitude).
+// https://clickhouse.com/docs/en/sql-reference/functions/geo/geohash#geohashencode
+func (query *QueryBuilder) writeGeohash(fieldName string, precision int) {
+	query.WriteString("geohashEncode(")
+	query.writeLongitude(fieldName)
+	query.WriteString(", ")
+	query.writeLatitude(fieldName)
+	query.WriteString(", ")
+	// Precision must be a constant UInt8, so we can't pass it as an Int64 parameter. It is
+	// validated by db.Split.ValidateGeoGrid, so it's safe to write directly.
+	query.WriteString(strconv.Itoa(precision))
+	query.WriteByte(')')
+}
+
+// Writes the "zoom/x/y" key of the map tile containing the given Point column, in the same format
+// as Elasticsearch's geotile_grid aggregation. ClickHouse has no built-in function for this, so we
+// use the standard formula for Web Mercator tiles:
+// https://wiki.openstreetmap.org/wiki/Slippy_map_tilenames#Mathematics
+func (query *QueryBuilder) writeGeotileKey(fieldName string, zoom int) {
+	// Zoom is validated by db.Split.ValidateGeoGrid, so it's safe to write directly
+	zoomString := strconv.Itoa(zoom)
+	tileCount := strconv.Itoa(1 << zoom)
+	maxTile := strconv.Itoa(1<<zoom - 1)
+
+	query.WriteString("concat('")
+	query.WriteString(zoomString)
+	query.WriteString("/', toString(least(toUInt32(floor((")
+	query.writeLongitude(fieldName)
+	query.WriteString(" + 180) / 360 * ")
+	query.WriteString(tileCount)
+	query.WriteString(")), ")
+	query.WriteString(maxTile)
+	query.WriteString(")), '/', toString(least(toUInt32(greatest(floor((1 - log(tan(radians(")
+	query.writeClampedLatitude(fieldName)
+	query.WriteString(")) + 1 / cos(radians(")
+	query.writeClampedLatitude(fieldName)
+	query.WriteString("))) / pi()) / 2 * ")
+	query.WriteString(tileCount)
+	query.WriteString("), 0)), ")
+	query.WriteString(maxTile)
+	query.WriteString(")))")
+}
+
+func (query *QueryBuilder) writeLongitude(pointFieldName string) {
+	query.WriteString("tupleElement(")
+	query.AddIdentifier(pointFieldName)
+	query.WriteString(", 1)")
+}
+
+func (query *QueryBuilder) writeLatitude(pointFieldName string) {
+	query.WriteString("tupleElement(")
+	query.AddIdentifier(pointFieldName)
+	query.WriteString(", 2)")
+}
+
+// Web Mercator tiles only cover latitudes up to ±85.0511 degrees, so we clamp latitudes to that
+// range like Elasticsearch does.
+func (query *QueryBuilder) writeClampedLatitude(pointFieldName string) {
+	query.WriteString("greatest(least(")
+	query.writeLatitude(pointFieldName)
+	query.WriteString(", 85.0511287798), -85.0511287798)")
+}
+
+// Writes the given column for grouping by its values. For TAGS columns, this unnests the tags with
+// arrayJoin, so that each row is counted once under each of its tags.
+// https://clickhouse.com/docs/en/sql-reference/functions/array-join
+func (query *QueryBuilder) WriteColumnValues(fieldName string, dataType db.DataType) {
+	if dataType == db.DataTypeTags {
+		query.WriteString("arrayJoin(")
+		query.AddIdentifier(fieldName)
+		query.WriteByte(')')
+	} else {
+		query.AddIdentifier(fieldName)
+	}
+}
+
+// See db.Filter.ParseValue for the semantics of filters on TAGS columns.
+// https://clickhouse.com/docs/en/sql-reference/functions/array-functions
+func (query *QueryBuilder) writeTagsFilter(filter db.Filter, value db.DBValue) error {
+	switch filter.Operator {
+	case db.FilterEquals:
+		query.WriteString("has(")
+	case db.FilterNotEquals:
+		query.WriteString("NOT has(")
+	case db.FilterIsNull:
+		query.WriteString("empty(")
+	case db.FilterIsNotNull:
+		query.WriteString("notEmpty(")
+	default:
+		return fmt.Errorf("unsupported filter operator '%v' for tags", filter.Operator)
+	}
+
+	query.AddIdentifier(filter.FieldName)
+	if value != nil {
+		query.WriteString(", ")
+		if err := query.AddValueParameter(value, db.DataTypeText); err != nil {
+			return err
+		}
+	}
+	query.WriteByte(')')
+
 	return nil
 }
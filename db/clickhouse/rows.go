@@ -0,0 +1,261 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"hermannm.dev/analysis/db"
+	"hermannm.dev/wrap"
+)
+
+// ClickHouse uses LIMIT/OFFSET for pagination, with the row ID as the final sort key to keep page
+// boundaries stable.
+type rowsCursor struct {
+	Offset int `json:"offset"`
+}
+
+func (cursor *rowsCursor) Validate() error {
+	if cursor.Offset < 0 {
+		return fmt.Errorf("negative offset %d", cursor.Offset)
+	}
+	return nil
+}
+
+func (clickhouse ClickHouseDB) QueryRows(
+	ctx context.Context,
+	rowsQuery db.RowsQuery,
+	schema db.TableSchema,
+) (db.RowsResult, error) {
+	columns, err := rowsQuery.Validate(schema)
+	if err != nil {
+		return db.RowsResult{}, wrap.Error(err, "invalid rows query")
+	}
+
+	var cursor rowsCursor
+	if rowsQuery.Cursor != "" {
+		if err := db.DecodeCursor(rowsQuery.Cursor, &cursor); err != nil {
+			return db.RowsResult{}, err
+		}
+	}
+
+	query, err := translateRowsQuery(rowsQuery, columns, schema, cursor)
+	if err != nil {
+		return db.RowsResult{}, wrap.Error(err, "failed to parse rows query")
+	}
+
+	rows, err := clickhouse.conn.Query(query.WithParameters(ctx), query.String())
+	if err != nil {
+		return db.RowsResult{}, wrap.Error(err, "failed to execute rows query against ClickHouse")
+	}
+	defer rows.Close()
+
+	result, err := parseRowsResult(rows, columns, rowsQuery.Limit)
+	if err != nil {
+		return db.RowsResult{}, wrap.Error(err, "failed to parse rows query result")
+	}
+
+	if len(result.Rows) > rowsQuery.Limit {
+		result.Rows = result.Rows[:rowsQuery.Limit]
+		result.NextCursor, err = db.EncodeCursor(
+			rowsCursor{Offset: cursor.Offset + rowsQuery.Limit},
+		)
+		if err != nil {
+			return db.RowsResult{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func translateRowsQuery(
+	rowsQuery db.RowsQuery,
+	columns []db.Column,
+	schema db.TableSchema,
+	cursor rowsCursor,
+) (*QueryBuilder, error) {
+	var query QueryBuilder
+	query.WriteString("SELECT ")
+
+	for i, column := range columns {
+		query.AddIdentifier(column.Name)
+		if i != len(columns)-1 {
+			query.WriteString(", ")
+		}
+	}
+
+	query.WriteString(" FROM ")
+	query.AddIdentifier(schema.TableName)
+
+	for i, filter := range rowsQuery.Filters {
+		if i == 0 {
+			query.WriteString(" WHERE ")
+		} else {
+			query.WriteString(" AND ")
+		}
+
+		column, _ := schema.GetColumn(filter.FieldName) // Checked by RowsQuery.Validate
+		if err := query.WriteFilter(filter, column.DataType); err != nil {
+			return nil, wrap.Errorf(err, "invalid filter on column '%s'", filter.FieldName)
+		}
+	}
+
+	query.WriteString(" ORDER BY ")
+	for _, sort := range rowsQuery.SortBy {
+		query.AddIdentifier(sort.FieldName)
+		query.WriteByte(' ')
+		if ok := query.WriteSortOrder(sort.SortOrder); !ok {
+			return nil, fmt.Errorf("invalid sort order '%v'", sort.SortOrder)
+		}
+		query.WriteString(", ")
+	}
+	query.WriteString("`id`")
+
+	// Fetches one more row than the limit, to know if there is a next page
+	query.WriteString(" LIMIT ")
+	query.AddIntParameter(rowsQuery.Limit + 1)
+	query.WriteString(" OFFSET ")
+	query.AddIntParameter(cursor.Offset)
+
+	return &query, nil
+}
+
+func parseRowsResult(rows driver.Rows, columns []db.Column, limit int) (db.RowsResult, error) {
+	result := db.RowsResult{Columns: columns, Rows: make([][]db.DBValue, 0, limit+1)}
+
+	for rows.Next() {
+		row := make([]db.DBValue, len(columns))
+		scanTargets := make([]any, len(columns))
+
+		for i, column := range columns {
+			value, err := db.NewDBValue(column.DataType)
+			if err != nil {
+				return db.RowsResult{}, wrap.Errorf(
+					err,
+					"failed to initialize value for column '%s'",
+					column.Name,
+				)
+			}
+			row[i] = value
+
+			if column.DataType == db.DataTypeTags {
+				// TAGS columns are never NULL (see clickhouseColumnType), and ClickHouse can't scan
+				// arrays into pointers-to-pointers, so we scan them directly
+				scanTargets[i] = value.Pointer()
+			} else {
+				scanTargets[i] = newNullableScanTarget(value)
+			}
+		}
+
+		if err := rows.Scan(scanTargets...); err != nil {
+			return db.RowsResult{}, wrap.Error(err, "failed to scan ClickHouse result row")
+		}
+
+		for i, column := range columns {
+			if column.DataType == db.DataTypeTags {
+				// Empty tags are returned as NULL, like in Elasticsearch, where empty arrays are
+				// treated as missing values
+				if tags, _ := row[i].Value().([]string); len(tags) == 0 {
+					row[i] = nil
+				}
+				continue
+			}
+
+			isNull, err := setFromNullableScanTarget(row[i], scanTargets[i])
+			if err != nil {
+				return db.RowsResult{}, wrap.Errorf(
+					err,
+					"invalid value for column '%s'",
+					column.Name,
+				)
+			}
+			if isNull {
+				row[i] = nil
+			}
+		}
+
+		result.Rows = append(result.Rows, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return db.RowsResult{}, wrap.Error(err, "failed to read ClickHouse result rows")
+	}
+
+	return result, nil
+}
+
+// Returns a pointer-to-pointer for scanning into the given value, which is left nil if the scanned
+// value is NULL. Use setFromNullableScanTarget after scanning to set the value.
+func newNullableScanTarget(value db.DBValue) any {
+	return reflect.New(reflect.TypeOf(value.Pointer())).Interface()
+}
+
+func setFromNullableScanTarget(value db.DBValue, scanTarget any) (isNull bool, err error) {
+	pointer := reflect.ValueOf(scanTarget).Elem()
+	if pointer.IsNil() {
+		return true, nil
+	}
+
+	scannedValue := pointer.Elem().Interface()
+	if ok := value.Set(scannedValue); !ok {
+		return false, fmt.Errorf("failed to set scanned value '%v'", scannedValue)
+	}
+
+	return false, nil
+}
+
+func (clickhouse ClickHouseDB) QueryDistinctValues(
+	ctx context.Context,
+	valuesQuery db.DistinctValuesQuery,
+	schema db.TableSchema,
+) ([]db.DistinctValue, error) {
+	column, err := valuesQuery.Validate(schema)
+	if err != nil {
+		return nil, wrap.Error(err, "invalid distinct values query")
+	}
+
+	var query QueryBuilder
+	query.WriteString("SELECT ")
+	query.WriteColumnValues(column.Name, column.DataType)
+	query.WriteString(" AS distinct_value, count() AS value_count FROM ")
+	query.AddIdentifier(schema.TableName)
+	query.WriteString(" WHERE distinct_value IS NOT NULL")
+	if valuesQuery.SearchPrefix != "" {
+		// https://clickhouse.com/docs/en/sql-reference/functions/string-functions#startswith
+		query.WriteString(" AND startsWith(toString(distinct_value), ")
+		query.AddStringParameter(valuesQuery.SearchPrefix)
+		query.WriteByte(')')
+	}
+	query.WriteString(" GROUP BY distinct_value")
+	query.WriteString(" ORDER BY value_count DESC, distinct_value ASC")
+	query.WriteString(" LIMIT ")
+	query.AddIntParameter(valuesQuery.Limit)
+
+	rows, err := clickhouse.conn.Query(query.WithParameters(ctx), query.String())
+	if err != nil {
+		return nil, wrap.Error(err, "failed to execute distinct values query against ClickHouse")
+	}
+	defer rows.Close()
+
+	values := make([]db.DistinctValue, 0, valuesQuery.Limit)
+	for rows.Next() {
+		value, err := db.NewDBValue(column.DataType.ValueDataType())
+		if err != nil {
+			return nil, wrap.Error(err, "failed to initialize distinct value")
+		}
+
+		var count uint64 // ClickHouse's count() returns UInt64
+		if err := rows.Scan(value.Pointer(), &count); err != nil {
+			return nil, wrap.Error(err, "failed to scan ClickHouse result row")
+		}
+
+		values = append(values, db.DistinctValue{Value: value, Count: int64(count)})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrap.Error(err, "failed to read ClickHouse result rows")
+	}
+
+	return values, nil
+}
@@ -2,11 +2,108 @@ package clickhouse
 
 import (
 	"context"
+	"reflect"
 
 	"hermannm.dev/analysis/db"
 	"hermannm.dev/wrap"
 )
 
+type storedSchemaColumn struct {
+	name     string
+	dataType string
+	// Returns a pointer to the field of the stored schema that the column maps to.
+	field func(storedSchema *db.StoredTableSchema) any
+}
+
+// Columns of the stored schemas table. New columns must be added at the end, so that they match
+// the column order of tables created before they were added (see CreateStoredSchemasTable).
+var storedSchemaColumns = []storedSchemaColumn{
+	{
+		name:     db.StoredSchemaName,
+		dataType: "String",
+		field:    func(storedSchema *db.StoredTableSchema) any { return &storedSchema.TableName },
+	},
+	{
+		name:     db.StoredSchemaColumnNames,
+		dataType: "Array(String)",
+		field:    func(storedSchema *db.StoredTableSchema) any { return &storedSchema.ColumnNames },
+	},
+	{
+		name:     db.StoredSchemaColumnDataTypes,
+		dataType: "Array(Int8)",
+		field:    func(storedSchema *db.StoredTableSchema) any { return &storedSchema.DataTypes },
+	},
+	{
+		name:     db.StoredSchemaColumnOptionals,
+		dataType: "Array(Bool)",
+		field:    func(storedSchema *db.StoredTableSchema) any { return &storedSchema.Optionals },
+	},
+	{
+		name:     db.StoredSchemaParseOptions,
+		dataType: "String",
+		field:    func(storedSchema *db.StoredTableSchema) any { return &storedSchema.ParseOptions },
+	},
+	{
+		name:     db.StoredSchemaColumnPrecisions,
+		dataType: "Array(Int8)",
+		field:    func(storedSchema *db.StoredTableSchema) any { return &storedSchema.Precisions },
+	},
+	{
+		name:     db.StoredSchemaColumnScales,
+		dataType: "Array(Int8)",
+		field:    func(storedSchema *db.StoredTableSchema) any { return &storedSchema.Scales },
+	},
+	{
+		name:     db.StoredSchemaColumnTagSeparators,
+		dataType: "Array(String)",
+		field: func(storedSchema *db.StoredTableSchema) any {
+			return &storedSchema.TagSeparators
+		},
+	},
+	{
+		name:     db.StoredSchemaColumnLongitudeColumns,
+		dataType: "Array(String)",
+		field: func(storedSchema *db.StoredTableSchema) any {
+			return &storedSchema.LongitudeColumns
+		},
+	},
+	{
+		name:     db.StoredSchemaColumnDateTimeFormats,
+		dataType: "Array(String)",
+		field: func(storedSchema *db.StoredTableSchema) any {
+			return &storedSchema.DateTimeFormats
+		},
+	},
+	{
+		name:     db.StoredSchemaColumnNumberFormats,
+		dataType: "Array(String)",
+		field: func(storedSchema *db.StoredTableSchema) any {
+			return &storedSchema.NumberFormats
+		},
+	},
+	{
+		name:     db.StoredSchemaColumnLabels,
+		dataType: "Array(String)",
+		field: func(storedSchema *db.StoredTableSchema) any {
+			return &storedSchema.Labels
+		},
+	},
+	{
+		name:     db.StoredSchemaColumnDescriptions,
+		dataType: "Array(String)",
+		field: func(storedSchema *db.StoredTableSchema) any {
+			return &storedSchema.Descriptions
+		},
+	},
+	{
+		name:     db.StoredSchemaColumnUnits,
+		dataType: "Array(String)",
+		field: func(storedSchema *db.StoredTableSchema) any {
+			return &storedSchema.Units
+		},
+	},
+}
+
 func (clickhouse ClickHouseDB) CreateStoredSchemasTable(ctx context.Context) error {
 	var query QueryBuilder
 	query.WriteString("CREATE TABLE IF NOT EXISTS ")
@@ -15,17 +112,15 @@ func (clickhouse ClickHouseDB) CreateStoredSchemasTable(ctx context.Context) err
 	query.WriteQuotedIdentifier(db.StoredSchemasTable)
 	query.WriteString(" (")
 
-	query.WriteQuotedIdentifier(db.StoredSchemaName)
-	query.WriteString(" String, ")
-
-	query.WriteQuotedIdentifier(db.StoredSchemaColumnNames)
-	query.WriteString(" Array(String), ")
-
-	query.WriteQuotedIdentifier(db.StoredSchemaColumnDataTypes)
-	query.WriteString(" Array(Int8), ")
-
-	query.WriteQuotedIdentifier(db.StoredSchemaColumnOptionals)
-	query.WriteString(" Array(Bool))")
+	for i, column := range storedSchemaColumns {
+		query.WriteQuotedIdentifier(column.name)
+		query.WriteByte(' ')
+		query.WriteString(column.dataType)
+		if i != len(storedSchemaColumns)-1 {
+			query.WriteString(", ")
+		}
+	}
+	query.WriteByte(')')
 
 	query.WriteString(" ENGINE = MergeTree()")
 	query.WriteString(" PRIMARY KEY (")
@@ -36,6 +131,23 @@ func (clickhouse ClickHouseDB) CreateStoredSchemasTable(ctx context.Context) err
 		return wrap.Error(err, "ClickHouse table creation query failed")
 	}
 
+	// If the table was created by an earlier version with fewer columns, we add the missing ones.
+	// Rows stored before then get the column type's default value (empty string/array), which
+	// db.StoredTableSchema.ToSchema handles.
+	for _, column := range storedSchemaColumns {
+		var query QueryBuilder
+		query.WriteString("ALTER TABLE ")
+		query.WriteQuotedIdentifier(db.StoredSchemasTable)
+		query.WriteString(" ADD COLUMN IF NOT EXISTS ")
+		query.WriteQuotedIdentifier(column.name)
+		query.WriteByte(' ')
+		query.WriteString(column.dataType)
+
+		if err := clickhouse.conn.Exec(query.WithParameters(ctx), query.String()); err != nil {
+			return wrap.Errorf(err, "failed to add column '%s' to schemas table", column.name)
+		}
+	}
+
 	return nil
 }
 
@@ -51,17 +163,33 @@ func (clickhouse ClickHouseDB) StoreTableSchema(
 
 	var query QueryBuilder
 	query.WriteString("INSERT INTO ")
-	// Ignores error, as this is a safe internal identifier
+	// Ignores errors, as these are safe internal identifiers
 	query.WriteQuotedIdentifier(db.StoredSchemasTable)
-	query.WriteString(" VALUES (?, ?, ?, ?)")
+	query.WriteString(" (")
+
+	values := make([]any, len(storedSchemaColumns))
+	for i, column := range storedSchemaColumns {
+		query.WriteQuotedIdentifier(column.name)
+		if i != len(storedSchemaColumns)-1 {
+			query.WriteString(", ")
+		}
+
+		values[i] = reflect.ValueOf(column.field(&storedSchema)).Elem().Interface()
+	}
+
+	query.WriteString(") VALUES (")
+	for i := range storedSchemaColumns {
+		query.WriteByte('?')
+		if i != len(storedSchemaColumns)-1 {
+			query.WriteString(", ")
+		}
+	}
+	query.WriteByte(')')
 
 	if err := clickhouse.conn.Exec(
 		query.WithParameters(ctx),
 		query.String(),
-		storedSchema.TableName,
-		storedSchema.ColumnNames,
-		storedSchema.DataTypes,
-		storedSchema.Optionals,
+		values...,
 	); err != nil {
 		return wrap.Error(err, "ClickHouse schema insertion query failed")
 	}
@@ -73,15 +201,19 @@ func (clickhouse ClickHouseDB) GetTableSchema(
 	ctx context.Context,
 	table string,
 ) (db.TableSchema, error) {
+	var storedSchema db.StoredTableSchema
+	scanTargets := make([]any, len(storedSchemaColumns))
+
 	var query QueryBuilder
 	query.WriteString("SELECT ")
-	query.AddIdentifier(db.StoredSchemaName)
-	query.WriteString(", ")
-	query.AddIdentifier(db.StoredSchemaColumnNames)
-	query.WriteString(", ")
-	query.AddIdentifier(db.StoredSchemaColumnDataTypes)
-	query.WriteString(", ")
-	query.AddIdentifier(db.StoredSchemaColumnOptionals)
+	for i, column := range storedSchemaColumns {
+		query.AddIdentifier(column.name)
+		if i != len(storedSchemaColumns)-1 {
+			query.WriteString(", ")
+		}
+
+		scanTargets[i] = column.field(&storedSchema)
+	}
 	query.WriteString(" FROM ")
 	query.AddIdentifier(db.StoredSchemasTable)
 	query.WriteString(" WHERE (")
@@ -95,13 +227,7 @@ func (clickhouse ClickHouseDB) GetTableSchema(
 		return db.TableSchema{}, wrap.Error(err, "ClickHouse schema fetching query failed")
 	}
 
-	var storedSchema db.StoredTableSchema
-	if err := result.Scan(
-		&storedSchema.TableName,
-		&storedSchema.ColumnNames,
-		&storedSchema.DataTypes,
-		&storedSchema.Optionals,
-	); err != nil {
+	if err := result.Scan(scanTargets...); err != nil {
 		return db.TableSchema{}, wrap.Error(err, "failed to parse table schema from database")
 	}
 
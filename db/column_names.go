@@ -0,0 +1,65 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Column names that the databases use for their own columns.
+var reservedColumnNames = []string{"id"}
+
+// Turns the given headers (such as from the first row of a CSV file) into column names that are
+// safe to use in both ClickHouse and Elasticsearch, and unique within the table:
+//   - Characters other than letters, digits and underscores are replaced with underscores, since
+//     names with both kinds of quotes can't be quoted in ClickHouse, and dots create nested objects
+//     in Elasticsearch
+//   - Repeated underscores are collapsed, and leading and trailing ones removed, since fields with
+//     leading underscores are reserved in Elasticsearch
+//   - Blank names become "column_N", where N is the 1-based index of the column
+//   - Names that are reserved or already taken (case-insensitively) get a "_2", "_3" etc. suffix
+func NormalizeColumnNames(headers []string) []string {
+	names := make([]string, len(headers))
+	takenNames := make(map[string]struct{}, len(headers)+len(reservedColumnNames))
+	for _, reservedName := range reservedColumnNames {
+		takenNames[reservedName] = struct{}{}
+	}
+
+	for i, header := range headers {
+		baseName := normalizeColumnName(header)
+		if baseName == "" {
+			baseName = fmt.Sprintf("column_%d", i+1)
+		}
+
+		name := baseName
+		for suffix := 2; ; suffix++ {
+			if _, taken := takenNames[strings.ToLower(name)]; !taken {
+				break
+			}
+			name = fmt.Sprintf("%s_%d", baseName, suffix)
+		}
+
+		takenNames[strings.ToLower(name)] = struct{}{}
+		names[i] = name
+	}
+
+	return names
+}
+
+func normalizeColumnName(header string) string {
+	var name strings.Builder
+	name.Grow(len(header))
+
+	previousWasUnderscore := true // Skips leading underscores
+	for _, char := range header {
+		if unicode.IsLetter(char) || unicode.IsDigit(char) {
+			name.WriteRune(char)
+			previousWasUnderscore = false
+		} else if !previousWasUnderscore {
+			name.WriteByte('_')
+			previousWasUnderscore = true
+		}
+	}
+
+	return strings.TrimSuffix(name.String(), "_")
+}
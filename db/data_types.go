@@ -14,6 +14,12 @@ const (
 	DataTypeFloat
 	DataTypeDateTime
 	DataTypeUUID
+	DataTypeBool
+	DataTypeDate
+	DataTypeDecimal
+	DataTypeCategory
+	DataTypeTags
+	DataTypeGeoPoint
 )
 
 var dataTypeMap = enumnames.NewMap(map[DataType]string{
@@ -22,26 +28,78 @@ var dataTypeMap = enumnames.NewMap(map[DataType]string{
 	DataTypeFloat:    "FLOAT",
 	DataTypeDateTime: "DATETIME",
 	DataTypeUUID:     "UUID",
+	DataTypeBool:     "BOOLEAN",
+	DataTypeDate:     "DATE",
+	DataTypeDecimal:  "DECIMAL",
+	DataTypeCategory: "CATEGORY",
+	DataTypeTags:     "TAGS",
+	DataTypeGeoPoint: "GEO_POINT",
 })
 
+// The maximum precision of DECIMAL columns, which is the limit of ClickHouse's largest Decimal
+// type.
+// https://clickhouse.com/docs/en/sql-reference/data-types/decimal
+const MaxDecimalPrecision = 76
+
+// Format of DATE values, both in CSV fields and when encoded to JSON.
+const DateFormat = "2006-01-02"
+
 func (dataType DataType) IsValid() bool {
 	return dataTypeMap.ContainsKey(dataType)
 }
 
 func (dataType DataType) IsValidForAggregation() error {
 	switch dataType {
-	case DataTypeInt, DataTypeFloat:
+	case DataTypeInt, DataTypeFloat, DataTypeDecimal:
 		return nil
 	default:
 		return fmt.Errorf(
-			"aggregation can only be done on %v/%v columns, not %v",
+			"aggregation can only be done on %v/%v/%v columns, not %v",
 			DataTypeInt,
 			DataTypeFloat,
+			DataTypeDecimal,
+			dataType,
+		)
+	}
+}
+
+func (dataType DataType) IsValidForPrefixSearch() error {
+	switch dataType {
+	case DataTypeText, DataTypeUUID, DataTypeCategory, DataTypeTags:
+		return nil
+	default:
+		return fmt.Errorf(
+			"prefix search can only be done on %v/%v/%v/%v columns, not %v",
+			DataTypeText,
+			DataTypeUUID,
+			DataTypeCategory,
+			DataTypeTags,
 			dataType,
 		)
 	}
 }
 
+func (dataType DataType) IsNumeric() bool {
+	return dataType == DataTypeInt || dataType == DataTypeFloat || dataType == DataTypeDecimal
+}
+
+func (dataType DataType) IsTemporal() bool {
+	return dataType == DataTypeDateTime || dataType == DataTypeDate
+}
+
+// Returns the data type of the individual values that a column of this data type is split, filtered
+// and counted by. For TAGS columns, this is TEXT, as each tag is a separate value. For GEO_POINT
+// columns, this is also TEXT, as they are split by the keys of geohash/geotile grid cells. For all
+// other data types, it is the data type itself.
+func (dataType DataType) ValueDataType() DataType {
+	switch dataType {
+	case DataTypeTags, DataTypeGeoPoint:
+		return DataTypeText
+	default:
+		return dataType
+	}
+}
+
 func (dataType DataType) String() string {
 	return dataTypeMap.GetNameOrFallback(dataType, "INVALID_DATA_TYPE")
 }
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Special values for Column.DateTimeFormat, for DATETIME columns stored as integer Unix timestamps.
+const (
+	DateTimeFormatUnixSeconds      = "UNIX_SECONDS"
+	DateTimeFormatUnixMilliseconds = "UNIX_MILLISECONDS"
+)
+
+// Layouts (see time.Layout) that we recognize when deducing DATETIME columns, in order of
+// preference when a column's values match several of them. Layouts without a time zone are parsed
+// as UTC.
+var DateTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"01/02/2006 15:04:05",
+	"01/02/2006 15:04",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+}
+
+// Layouts (see time.Layout) that we recognize when deducing DATE columns, in order of preference
+// when a column's values match several of them. This is the case for 01/02/2006 and 02/01/2006 when
+// all days in the column are 12 or less, in which case we assume the US format.
+var DateLayouts = []string{
+	DateFormat,
+	"02.01.2006",
+	"01/02/2006",
+	"02/01/2006",
+}
+
+// We only deduce integer columns as Unix timestamps if their names contain one of these, as most
+// integer columns with values in the timestamp range are not timestamps.
+var unixTimestampColumnNameHints = []string{"time", "date", "_at", "created", "updated"}
+
+// Ranges of Unix timestamps that we deduce as DATETIME, from 2000-01-01 to 2100-01-01.
+const (
+	minUnixSeconds      = 946684800
+	maxUnixSeconds      = 4102444800
+	minUnixMilliseconds = minUnixSeconds * 1000
+	maxUnixMilliseconds = maxUnixSeconds * 1000
+)
+
+// Returns the layouts from the given list that the field can be parsed with.
+func matchingLayouts(field string, layouts []string) []string {
+	var matching []string
+	for _, layout := range layouts {
+		if _, err := time.Parse(layout, field); err == nil {
+			matching = append(matching, layout)
+		}
+	}
+	return matching
+}
+
+// Returns the layouts that are in both the given lists, keeping the order of the first list.
+func intersectLayouts(layouts []string, otherLayouts []string) []string {
+	var intersection []string
+	for _, layout := range layouts {
+		for _, otherLayout := range otherLayouts {
+			if layout == otherLayout {
+				intersection = append(intersection, layout)
+				break
+			}
+		}
+	}
+	return intersection
+}
+
+// Returns the Unix timestamp format for an INTEGER column with the given name and value range, or
+// "" if the column does not look like a Unix timestamp column.
+func deduceUnixTimestampFormat(columnName string, minValue int64, maxValue int64) string {
+	lowercaseName := strings.ToLower(columnName)
+	hasNameHint := false
+	for _, hint := range unixTimestampColumnNameHints {
+		if strings.Contains(lowercaseName, hint) {
+			hasNameHint = true
+			break
+		}
+	}
+	if !hasNameHint {
+		return ""
+	}
+
+	if minValue >= minUnixSeconds && maxValue <= maxUnixSeconds {
+		return DateTimeFormatUnixSeconds
+	}
+	if minValue >= minUnixMilliseconds && maxValue <= maxUnixMilliseconds {
+		return DateTimeFormatUnixMilliseconds
+	}
+	return ""
+}
+
+// Parses a field in a DATETIME or DATE column, using the column's DateTimeFormat.
+func (column Column) parseDateTime(field string) (time.Time, error) {
+	switch column.DateTimeFormat {
+	case DateTimeFormatUnixSeconds, DateTimeFormatUnixMilliseconds:
+		timestamp, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("'%s' is not a valid Unix timestamp", field)
+		}
+		if column.DateTimeFormat == DateTimeFormatUnixSeconds {
+			return time.Unix(timestamp, 0).UTC(), nil
+		}
+		return time.UnixMilli(timestamp).UTC(), nil
+	case "":
+		if column.DataType == DataTypeDate {
+			return time.Parse(DateFormat, field)
+		}
+		return time.Parse(time.RFC3339, field)
+	default:
+		return time.Parse(column.DateTimeFormat, field)
+	}
+}
+
+func (column Column) validateDateTimeFormat() error {
+	switch column.DateTimeFormat {
+	case "":
+		return nil
+	case DateTimeFormatUnixSeconds, DateTimeFormatUnixMilliseconds:
+		if column.DataType != DataTypeDateTime {
+			return fmt.Errorf(
+				"Unix timestamp formats can only be used for %v columns",
+				DataTypeDateTime,
+			)
+		}
+		return nil
+	default:
+		if column.DataType != DataTypeDateTime && column.DataType != DataTypeDate {
+			return fmt.Errorf(
+				"date time format can only be set on %v/%v columns",
+				DataTypeDateTime,
+				DataTypeDate,
+			)
+		}
+		return nil
+	}
+}
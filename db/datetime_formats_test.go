@@ -0,0 +1,156 @@
+package db
+
+import "testing"
+
+func TestDeduceUnixTimestampFormat(t *testing.T) {
+	testCases := []struct {
+		name       string
+		columnName string
+		min        int64
+		max        int64
+		want       string
+	}{
+		{
+			name:       "Seconds",
+			columnName: "created_at",
+			min:        1696118400,
+			max:        1698796800,
+			want:       DateTimeFormatUnixSeconds,
+		},
+		{
+			name:       "Milliseconds",
+			columnName: "Timestamp",
+			min:        1696118400000,
+			max:        1698796800000,
+			want:       DateTimeFormatUnixMilliseconds,
+		},
+		{
+			name:       "Name without hint",
+			columnName: "customer_id",
+			min:        1696118400,
+			max:        1698796800,
+			want:       "",
+		},
+		{
+			name:       "Below timestamp range",
+			columnName: "updated",
+			min:        12,
+			max:        1698796800,
+			want:       "",
+		},
+		{
+			name:       "Between seconds and milliseconds",
+			columnName: "updated",
+			min:        1696118400,
+			max:        1698796800000,
+			want:       "",
+		},
+		{
+			name:       "Above timestamp range",
+			columnName: "date",
+			min:        1696118400000000,
+			max:        1698796800000000,
+			want:       "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			format := deduceUnixTimestampFormat(testCase.columnName, testCase.min, testCase.max)
+			if format != testCase.want {
+				t.Errorf("expected format %q, got %q", testCase.want, format)
+			}
+		})
+	}
+}
+
+func TestDeduceDateTimeColumns(t *testing.T) {
+	testCases := []struct {
+		name         string
+		columnName   string
+		fields       []string
+		wantDataType DataType
+		wantFormat   string
+	}{
+		{
+			name:         "Unix seconds",
+			columnName:   "created_at",
+			fields:       []string{"1696118400", "1698796800", ""},
+			wantDataType: DataTypeDateTime,
+			wantFormat:   DateTimeFormatUnixSeconds,
+		},
+		{
+			name:         "Unix milliseconds",
+			columnName:   "updated",
+			fields:       []string{"1696118400000", "1698796800123"},
+			wantDataType: DataTypeDateTime,
+			wantFormat:   DateTimeFormatUnixMilliseconds,
+		},
+		{
+			name:         "IDs in timestamp range",
+			columnName:   "id",
+			fields:       []string{"1696118400", "1698796800"},
+			wantDataType: DataTypeInt,
+		},
+		{
+			name:         "Small integers in timestamp column",
+			columnName:   "time",
+			fields:       []string{"1", "2", "3"},
+			wantDataType: DataTypeInt,
+		},
+		{
+			name:         "RFC 3339",
+			columnName:   "time",
+			fields:       []string{"2023-10-01T12:00:00Z", "2023-10-02T13:30:00+02:00"},
+			wantDataType: DataTypeDateTime,
+		},
+		{
+			name:         "Space-separated datetime",
+			columnName:   "time",
+			fields:       []string{"2023-10-01 12:00:00", "2023-10-02 13:30:00"},
+			wantDataType: DataTypeDateTime,
+			wantFormat:   "2006-01-02 15:04:05",
+		},
+		{
+			name:         "Ambiguous day and month",
+			columnName:   "date",
+			fields:       []string{"01/02/2023", "03/04/2023"},
+			wantDataType: DataTypeDate,
+			wantFormat:   "01/02/2006",
+		},
+		{
+			name:         "Day after 12",
+			columnName:   "date",
+			fields:       []string{"01/02/2023", "25/12/2023"},
+			wantDataType: DataTypeDate,
+			wantFormat:   "02/01/2006",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			column := deduceTestColumn(t, testCase.columnName, testCase.fields)
+			if column.DataType != testCase.wantDataType {
+				t.Errorf("expected data type %v, got %v", testCase.wantDataType, column.DataType)
+			}
+			if column.DateTimeFormat != testCase.wantFormat {
+				t.Errorf("expected format %q, got %q", testCase.wantFormat, column.DateTimeFormat)
+			}
+		})
+	}
+}
+
+// Deduces a column from the given fields, passing each field as a single-column row.
+func deduceTestColumn(t *testing.T, columnName string, fields []string) Column {
+	t.Helper()
+
+	deducer := NewSchemaDeducer([]string{columnName}, ParseOptions{})
+	for i, field := range fields {
+		if err := deducer.DeduceDataTypesFromRow([]string{field}, i+1); err != nil {
+			t.Fatalf("unexpected error when deducing data types: %v", err)
+		}
+	}
+
+	schema, _ := deducer.Schema()
+	return schema.Columns[0]
+}
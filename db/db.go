@@ -9,9 +9,24 @@ type AnalysisDB interface {
 		table string,
 	) (AnalysisResult, error)
 
+	QueryRows(ctx context.Context, query RowsQuery, schema TableSchema) (RowsResult, error)
+
+	QueryDistinctValues(
+		ctx context.Context,
+		query DistinctValuesQuery,
+		schema TableSchema,
+	) ([]DistinctValue, error)
+
+	ProfileTable(ctx context.Context, schema TableSchema) (TableProfile, error)
+
 	CreateTable(ctx context.Context, schema TableSchema) error
 
-	IngestData(ctx context.Context, data DataSource, schema TableSchema) error
+	IngestData(
+		ctx context.Context,
+		data DataSource,
+		schema TableSchema,
+		options IngestionOptions,
+	) (IngestionReport, error)
 
 	DropTable(ctx context.Context, table string) (alreadyDropped bool, err error)
 
@@ -3,7 +3,11 @@ package db
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/shopspring/decimal"
 )
 
 type DBValue interface {
@@ -29,9 +33,38 @@ type timeDBValue struct {
 	dbValue[time.Time]
 }
 
+// A timeDBValue for DATE columns, which is encoded to and from JSON in DateFormat rather than as a
+// full timestamp.
+type dateDBValue struct {
+	timeDBValue
+}
+
+// Implements DBValue for DECIMAL columns. decimal.Decimal is not comparable with ==, so this
+// cannot use dbValue.
+type decimalDBValue struct {
+	value decimal.Decimal
+}
+
+// Implements DBValue for TAGS columns. Slices are not comparable with ==, so this cannot use
+// dbValue.
+type tagsDBValue struct {
+	value []string
+}
+
+// Implements DBValue.LessThan for GEO_POINT values, ordering by latitude and then longitude. The
+// point is encoded to and from JSON as an object with "lat" and "lon" fields.
+type geoPointDBValue struct {
+	dbValue[orb.Point]
+}
+
+// Implements DBValue.LessThan for bool, ordering false before true.
+type boolDBValue struct {
+	dbValue[bool]
+}
+
 func NewDBValue(dataType DataType) (DBValue, error) {
 	switch dataType {
-	case DataTypeText, DataTypeUUID:
+	case DataTypeText, DataTypeUUID, DataTypeCategory:
 		return &orderedDBValue[string]{}, nil
 	case DataTypeInt:
 		return &orderedDBValue[int64]{}, nil
@@ -39,6 +72,16 @@ func NewDBValue(dataType DataType) (DBValue, error) {
 		return &orderedDBValue[float64]{}, nil
 	case DataTypeDateTime:
 		return &timeDBValue{}, nil
+	case DataTypeDate:
+		return &dateDBValue{}, nil
+	case DataTypeBool:
+		return &boolDBValue{}, nil
+	case DataTypeDecimal:
+		return &decimalDBValue{}, nil
+	case DataTypeTags:
+		return &tagsDBValue{}, nil
+	case DataTypeGeoPoint:
+		return &geoPointDBValue{}, nil
 	default:
 		return nil, fmt.Errorf("unrecognized data type %v", dataType)
 	}
@@ -83,6 +126,13 @@ func (dbValue *timeDBValue) LessThan(value any) (less bool, err error) {
 	return false, fmt.Errorf("failed to convert '%v' to time.Time", value)
 }
 
+func (dbValue *boolDBValue) LessThan(value any) (less bool, err error) {
+	if value, ok := value.(bool); ok {
+		return !dbValue.value && value, nil
+	}
+	return false, fmt.Errorf("failed to convert '%v' to bool", value)
+}
+
 func (dbValue dbValue[T]) MarshalJSON() ([]byte, error) {
 	return json.Marshal(dbValue.value)
 }
@@ -90,3 +140,134 @@ func (dbValue dbValue[T]) MarshalJSON() ([]byte, error) {
 func (dbValue *dbValue[T]) UnmarshalJSON(bytes []byte) error {
 	return json.Unmarshal(bytes, &dbValue.value)
 }
+
+func (dbValue dateDBValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dbValue.value.Format(DateFormat))
+}
+
+func (dbValue *dateDBValue) UnmarshalJSON(bytes []byte) error {
+	var date string
+	if err := json.Unmarshal(bytes, &date); err != nil {
+		return err
+	}
+
+	value, err := time.Parse(DateFormat, date)
+	if err != nil {
+		return err
+	}
+
+	dbValue.value = value
+	return nil
+}
+
+func (dbValue *decimalDBValue) Value() any {
+	return dbValue.value
+}
+
+func (dbValue *decimalDBValue) Pointer() any {
+	return &dbValue.value
+}
+
+func (dbValue *decimalDBValue) Set(value any) (ok bool) {
+	if value, ok := value.(decimal.Decimal); ok {
+		dbValue.value = value
+		return true
+	} else {
+		return false
+	}
+}
+
+func (dbValue *decimalDBValue) Equals(value any) bool {
+	if value, ok := value.(decimal.Decimal); ok {
+		return dbValue.value.Equal(value)
+	} else {
+		return false
+	}
+}
+
+func (dbValue *decimalDBValue) LessThan(value any) (less bool, err error) {
+	if value, ok := value.(decimal.Decimal); ok {
+		return dbValue.value.LessThan(value), nil
+	}
+	return false, fmt.Errorf("failed to convert '%v' to decimal", value)
+}
+
+// Encodes the decimal as a JSON string, as JSON numbers are often parsed as floating-point.
+func (dbValue decimalDBValue) MarshalJSON() ([]byte, error) {
+	return dbValue.value.MarshalJSON()
+}
+
+// Accepts both JSON strings and numbers.
+func (dbValue *decimalDBValue) UnmarshalJSON(bytes []byte) error {
+	return dbValue.value.UnmarshalJSON(bytes)
+}
+
+func (dbValue *tagsDBValue) Value() any {
+	return dbValue.value
+}
+
+func (dbValue *tagsDBValue) Pointer() any {
+	return &dbValue.value
+}
+
+func (dbValue *tagsDBValue) Set(value any) (ok bool) {
+	if value, ok := value.([]string); ok {
+		dbValue.value = value
+		return true
+	} else {
+		return false
+	}
+}
+
+func (dbValue *tagsDBValue) Equals(value any) bool {
+	if value, ok := value.([]string); ok {
+		return slices.Equal(dbValue.value, value)
+	} else {
+		return false
+	}
+}
+
+// Compares tags lexicographically, in the same way as ClickHouse compares arrays.
+func (dbValue *tagsDBValue) LessThan(value any) (less bool, err error) {
+	if value, ok := value.([]string); ok {
+		return slices.Compare(dbValue.value, value) < 0, nil
+	}
+	return false, fmt.Errorf("failed to convert '%v' to []string", value)
+}
+
+func (dbValue tagsDBValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dbValue.value)
+}
+
+func (dbValue *tagsDBValue) UnmarshalJSON(bytes []byte) error {
+	return json.Unmarshal(bytes, &dbValue.value)
+}
+
+func (dbValue *geoPointDBValue) LessThan(value any) (less bool, err error) {
+	if value, ok := value.(orb.Point); ok {
+		if dbValue.value.Lat() != value.Lat() {
+			return dbValue.value.Lat() < value.Lat(), nil
+		}
+		return dbValue.value.Lon() < value.Lon(), nil
+	}
+	return false, fmt.Errorf("failed to convert '%v' to orb.Point", value)
+}
+
+type geoPointJSON struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+}
+
+func (dbValue geoPointDBValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(geoPointJSON{Latitude: dbValue.value.Lat(), Longitude: dbValue.value.Lon()})
+}
+
+func (dbValue *geoPointDBValue) UnmarshalJSON(bytes []byte) error {
+	var point geoPointJSON
+	if err := json.Unmarshal(bytes, &point); err != nil {
+		return err
+	}
+
+	dbValue.value = orb.Point{point.Longitude, point.Latitude}
+	return nil
+}
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/sortorder"
+	"github.com/paulmach/orb"
+	"github.com/shopspring/decimal"
 	"hermannm.dev/analysis/db"
 	"hermannm.dev/wrap"
 )
@@ -19,17 +22,26 @@ func (elastic ElasticsearchDB) RunAnalysisQuery(
 	analysis db.AnalysisQuery,
 	table string,
 ) (db.AnalysisResult, error) {
-	query, err := elastic.translateAnalysisQuery(analysis, table)
+	var decimalScale int
+	if analysis.Aggregation.DataType == db.DataTypeDecimal {
+		var err error
+		decimalScale, err = elastic.getDecimalScale(ctx, table, analysis.Aggregation.FieldName)
+		if err != nil {
+			return db.AnalysisResult{}, err
+		}
+	}
+
+	query, err := elastic.translateAnalysisQuery(analysis, table, decimalScale)
 	if err != nil {
 		return db.AnalysisResult{}, wrap.Error(err, "failed to parse query")
 	}
 
-	response, err := executeAnalysisQuery(ctx, query)
+	response, err := executeSearch[analysisQueryResponse](ctx, query)
 	if err != nil {
 		return db.AnalysisResult{}, wrapElasticError(err, "failed to execute query")
 	}
 
-	analysisResult, err := parseAnalysisQueryResponse(response, analysis)
+	analysisResult, err := parseAnalysisQueryResponse(response, analysis, decimalScale)
 	if err != nil {
 		return db.AnalysisResult{}, wrap.Error(err, "failed to parse query result")
 	}
@@ -64,8 +76,9 @@ type analysisQueryResponse struct {
 func (elastic ElasticsearchDB) translateAnalysisQuery(
 	analysis db.AnalysisQuery,
 	table string,
+	decimalScale int,
 ) (*search.Search, error) {
-	analysisAggregation, err := createAnalysisAggregation(analysis.Aggregation)
+	analysisAggregation, err := createAnalysisAggregation(analysis.Aggregation, decimalScale)
 	if err != nil {
 		return nil, wrap.Error(err, "failed to create aggregation")
 	}
@@ -96,13 +109,34 @@ func (elastic ElasticsearchDB) translateAnalysisQuery(
 	return elastic.client.Search().Index(table).Aggregations(aggregations).Size(0), nil
 }
 
-func createAnalysisAggregation(aggregation db.Aggregation) (types.Aggregations, error) {
+func createAnalysisAggregation(
+	aggregation db.Aggregation,
+	decimalScale int,
+) (types.Aggregations, error) {
 	if err := aggregation.DataType.IsValidForAggregation(); err != nil {
 		return types.Aggregations{}, err
 	}
 
 	field := aggregation.FieldName
 
+	if aggregation.DataType == db.DataTypeDecimal && aggregation.Kind != db.AggregationCount {
+		script, err := scaledDecimalScript(field, decimalScale)
+		if err != nil {
+			return types.Aggregations{}, err
+		}
+
+		switch aggregation.Kind {
+		case db.AggregationSum:
+			return types.Aggregations{Sum: &types.SumAggregation{Script: script}}, nil
+		case db.AggregationAverage:
+			return types.Aggregations{Avg: &types.AverageAggregation{Script: script}}, nil
+		case db.AggregationMin:
+			return types.Aggregations{Min: &types.MinAggregation{Script: script}}, nil
+		case db.AggregationMax:
+			return types.Aggregations{Max: &types.MaxAggregation{Script: script}}, nil
+		}
+	}
+
 	switch aggregation.Kind {
 	case db.AggregationSum:
 		return types.Aggregations{Sum: &types.SumAggregation{Field: &field}}, nil
@@ -119,7 +153,82 @@ func createAnalysisAggregation(aggregation db.Aggregation) (types.Aggregations,
 	}
 }
 
+// Elasticsearch stores scaled_float fields as 64-bit integers multiplied by the scaling factor, but
+// computes aggregations on them as float64 values, which causes rounding drift in sums. We instead
+// aggregate the scaled integers, which float64 represents exactly as long as the absolute sum is
+// below 2^53, and then shift them back with scaledDecimalResult.
+//
+// Returns null for documents without the field, which aggregations then skip like missing values.
+func scaledDecimalScript(field string, decimalScale int) (*types.InlineScript, error) {
+	fieldParam, err := json.Marshal(field)
+	if err != nil {
+		return nil, wrap.Errorf(err, "failed to serialize field name '%s'", field)
+	}
+	scalingFactorParam, err := json.Marshal(math.Pow10(decimalScale))
+	if err != nil {
+		return nil, wrap.Error(err, "failed to serialize scaling factor")
+	}
+
+	return &types.InlineScript{
+		Source: "doc[params.field].size() == 0 ? null : " +
+			"Math.round(doc[params.field].value * params.scalingFactor)",
+		Params: map[string]json.RawMessage{
+			"field":         fieldParam,
+			"scalingFactor": scalingFactorParam,
+		},
+	}, nil
+}
+
+// Converts the result of an aggregation using scaledDecimalScript back to a decimal with the
+// column's scale. Sums, minimums and maximums of the scaled integers are integers themselves, so we
+// round away any floating-point noise before shifting. Averages are not exact to begin with, so
+// those keep their fractional digits.
+func scaledDecimalResult(value any, aggregationKind db.AggregationKind, decimalScale int) any {
+	scaled, isFloat := value.(float64)
+	if !isFloat {
+		return value
+	}
+
+	result := decimal.NewFromFloat(scaled)
+	if aggregationKind != db.AggregationAverage {
+		result = result.Round(0)
+	}
+	return result.Shift(int32(-decimalScale))
+}
+
+// Gets the scale of the DECIMAL column with the given name from the table's stored schema, which
+// we need in order to aggregate the column exactly (see scaledDecimalScript).
+func (elastic ElasticsearchDB) getDecimalScale(
+	ctx context.Context,
+	table string,
+	columnName string,
+) (int, error) {
+	schema, err := elastic.GetTableSchema(ctx, table)
+	if err != nil {
+		return 0, wrap.Error(err, "failed to get table schema for decimal aggregation")
+	}
+
+	column, ok := schema.GetColumn(columnName)
+	if !ok {
+		return 0, fmt.Errorf("aggregation field '%s' not found in table schema", columnName)
+	}
+	if column.DataType != db.DataTypeDecimal {
+		return 0, fmt.Errorf(
+			"aggregation field '%s' has data type %v in table schema, not %v",
+			columnName,
+			column.DataType,
+			db.DataTypeDecimal,
+		)
+	}
+
+	return column.Scale, nil
+}
+
 func createSplit(split db.Split, orderKey string) (types.Aggregations, error) {
+	if err := split.ValidateGeoGrid(); err != nil {
+		return types.Aggregations{}, err
+	}
+
 	field := split.FieldName
 
 	sortOrder, ok := sortOrderToElastic(split.SortOrder)
@@ -154,7 +263,7 @@ func createSplit(split db.Split, orderKey string) (types.Aggregations, error) {
 				Order:    orderField,
 			}}, nil
 		}
-	case db.DataTypeDateTime:
+	case db.DataTypeDateTime, db.DataTypeDate:
 		if !split.DateInterval.IsNone() {
 			dateInterval, ok := dateIntervalToElastic(split.DateInterval)
 			if !ok {
@@ -169,6 +278,29 @@ func createSplit(split db.Split, orderKey string) (types.Aggregations, error) {
 				Order:            orderField,
 			}}, nil
 		}
+	case db.DataTypeGeoPoint:
+		// Geo grid aggregations don't support custom ordering, and always order buckets by
+		// descending document count. See createSplit for why we increase the shard size.
+		shardSize := split.Limit*10 + 100
+
+		// Validated above to have either geohash precision or geotile zoom
+		if split.GeohashPrecision != 0 {
+			// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/search-aggregations-bucket-geohashgrid-aggregation.html
+			return types.Aggregations{GeohashGrid: &types.GeoHashGridAggregation{
+				Field:     &field,
+				Precision: split.GeohashPrecision,
+				Size:      &split.Limit,
+				ShardSize: &shardSize,
+			}}, nil
+		} else {
+			// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/search-aggregations-bucket-geotilegrid-aggregation.html
+			return types.Aggregations{GeotileGrid: &types.GeoTileGridAggregation{
+				Field:     &field,
+				Precision: &split.GeotileZoom,
+				Size:      &split.Limit,
+				ShardSize: &shardSize,
+			}}, nil
+		}
 	}
 
 	// If we get here, no interval was specified, so we want to use the Terms bucket aggregation to
@@ -191,38 +323,32 @@ func createSplit(split db.Split, orderKey string) (types.Aggregations, error) {
 	}}, nil
 }
 
-func executeAnalysisQuery(
-	ctx context.Context,
-	query *search.Search,
-) (analysisQueryResponse, error) {
+// Sends the given search query to Elasticsearch, and decodes the response body into the given
+// response type.
+func executeSearch[Response any](ctx context.Context, query *search.Search) (Response, error) {
+	var decodedResponse Response
+
 	response, err := query.Perform(ctx)
 	if err != nil {
-		return analysisQueryResponse{}, wrap.Error(err, "failed to send query to Elasticsearch")
+		return decodedResponse, wrap.Error(err, "failed to send query to Elasticsearch")
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode > 299 {
 		elasticErr := types.NewElasticsearchError()
 		if err := json.NewDecoder(response.Body).Decode(elasticErr); err != nil {
-			return analysisQueryResponse{}, wrap.Error(
-				err,
-				"failed to decode error from Elasticsearch",
-			)
+			return decodedResponse, wrap.Error(err, "failed to decode error from Elasticsearch")
 		}
 
 		if elasticErr.Status == 0 {
 			elasticErr.Status = response.StatusCode
 		}
 
-		return analysisQueryResponse{}, elasticErr
+		return decodedResponse, elasticErr
 	}
 
-	var decodedResponse analysisQueryResponse
 	if err := json.NewDecoder(response.Body).Decode(&decodedResponse); err != nil {
-		return analysisQueryResponse{}, wrap.Error(
-			err,
-			"failed to decode response from Elasticsearch",
-		)
+		return decodedResponse, wrap.Error(err, "failed to decode response from Elasticsearch")
 	}
 
 	return decodedResponse, nil
@@ -231,6 +357,7 @@ func executeAnalysisQuery(
 func parseAnalysisQueryResponse(
 	response analysisQueryResponse,
 	analysis db.AnalysisQuery,
+	decimalScale int,
 ) (db.AnalysisResult, error) {
 	analysisResult := db.NewAnalysisQueryResult(analysis)
 
@@ -249,6 +376,14 @@ func parseAnalysisQueryResponse(
 					columnSplit.Aggregation,
 				)
 			}
+			if analysis.Aggregation.DataType == db.DataTypeDecimal &&
+				analysis.Aggregation.Kind != db.AggregationCount {
+				aggregatedValue = scaledDecimalResult(
+					aggregatedValue,
+					analysis.Aggregation.Kind,
+					decimalScale,
+				)
+			}
 			if err := setResultValue(
 				handle.Aggregation,
 				aggregatedValue,
@@ -299,6 +434,65 @@ func setResultValue(target db.DBValue, value any, dataType db.DataType) error {
 			// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/date.html
 			value = time.UnixMilli(int64(float)).UTC()
 		}
+	case db.DataTypeDate:
+		// Document sources contain dates as we inserted them, while aggregation results give
+		// milliseconds since the Unix epoch
+		switch date := value.(type) {
+		case string:
+			parsed, err := time.Parse(db.DateFormat, date)
+			if err != nil {
+				return wrap.Errorf(err, "failed to parse date '%s'", date)
+			}
+			value = parsed
+		case float64:
+			value = time.UnixMilli(int64(date)).UTC()
+		}
+	case db.DataTypeDecimal:
+		// Document sources contain decimals as we inserted them (as strings), while other results
+		// on scaled_float fields are float64 (see scaledDecimalResult for exact aggregations)
+		switch number := value.(type) {
+		case string:
+			parsed, err := decimal.NewFromString(number)
+			if err != nil {
+				return wrap.Errorf(err, "failed to parse decimal '%s'", number)
+			}
+			value = parsed
+		case float64:
+			value = decimal.NewFromFloat(number)
+		}
+	case db.DataTypeTags:
+		// Document sources contain tags as JSON arrays, which are deserialized to []any
+		if array, isArray := value.([]any); isArray {
+			tags := make([]string, len(array))
+			for i, tag := range array {
+				tagString, isString := tag.(string)
+				if !isString {
+					return fmt.Errorf("expected tag to be string, got '%v'", tag)
+				}
+				tags[i] = tagString
+			}
+			value = tags
+		}
+	case db.DataTypeGeoPoint:
+		// Document sources contain geo points as we inserted them, i.e. as [longitude, latitude]
+		// arrays (see db.TableSchema.ConvertRowToMap)
+		if array, isArray := value.([]any); isArray {
+			if len(array) != 2 {
+				return fmt.Errorf("expected geo point to be [longitude, latitude], got '%v'", value)
+			}
+			longitude, isLongitudeFloat := array[0].(float64)
+			latitude, isLatitudeFloat := array[1].(float64)
+			if !isLongitudeFloat || !isLatitudeFloat {
+				return fmt.Errorf("expected geo point to be [longitude, latitude], got '%v'", value)
+			}
+			value = orb.Point{longitude, latitude}
+		}
+	case db.DataTypeBool:
+		// Bucket keys for boolean fields are 1 for true and 0 for false:
+		// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/boolean.html
+		if float, isFloat := value.(float64); isFloat {
+			value = float != 0
+		}
 	}
 
 	if ok := target.Set(value); !ok {
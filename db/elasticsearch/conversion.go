@@ -2,6 +2,9 @@ package elasticsearch
 
 import (
 	"fmt"
+	"math"
+	"strings"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/calendarinterval"
@@ -15,7 +18,7 @@ func schemaToElasticMappings(schema db.TableSchema) (*types.TypeMapping, error)
 	mappings.Properties = make(map[string]types.Property, len(schema.Columns))
 
 	for _, column := range schema.Columns {
-		property, err := dataTypeToElasticProperty(column.DataType)
+		property, err := columnToElasticProperty(column)
 		if err != nil {
 			return nil, wrap.Errorf(
 				err,
@@ -30,8 +33,8 @@ func schemaToElasticMappings(schema db.TableSchema) (*types.TypeMapping, error)
 	return mappings, nil
 }
 
-func dataTypeToElasticProperty(dataType db.DataType) (types.Property, error) {
-	switch dataType {
+func columnToElasticProperty(column db.Column) (types.Property, error) {
+	switch column.DataType {
 	case db.DataTypeText:
 		return types.NewKeywordProperty(), nil
 	case db.DataTypeInt:
@@ -40,13 +43,56 @@ func dataTypeToElasticProperty(dataType db.DataType) (types.Property, error) {
 		return types.NewFloatNumberProperty(), nil
 	case db.DataTypeDateTime:
 		return types.NewDateProperty(), nil
+	case db.DataTypeDate:
+		property := types.NewDateProperty()
+		// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/mapping-date-format.html#built-in-date-formats
+		format := "strict_date"
+		property.Format = &format
+		return property, nil
 	case db.DataTypeUUID:
 		return types.NewKeywordProperty(), nil
+	case db.DataTypeBool:
+		return types.NewBooleanProperty(), nil
+	case db.DataTypeTags:
+		// Any field in Elasticsearch can hold multiple values, so we map tags as a keyword field
+		// and index each field as an array
+		// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/array.html
+		return types.NewKeywordProperty(), nil
+	case db.DataTypeGeoPoint:
+		return types.NewGeoPointProperty(), nil
+	case db.DataTypeCategory:
+		// Global ordinals are used by terms aggregations on keyword fields. Building them eagerly
+		// on refresh rather than on the first aggregation speeds up splits, at a small cost for
+		// fields with few distinct values.
+		// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/eager-global-ordinals.html
+		property := types.NewKeywordProperty()
+		eagerGlobalOrdinals := true
+		property.EagerGlobalOrdinals = &eagerGlobalOrdinals
+		return property, nil
+	case db.DataTypeDecimal:
+		// scaled_float stores values as 64-bit integers multiplied by the scaling factor, which is
+		// exact as long as all the digits fit in the integer
+		// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/number.html#scaled-float-params
+		if column.Precision > maxScaledFloatPrecision {
+			return nil, fmt.Errorf(
+				"Elasticsearch supports at most %d digits of precision for %v columns",
+				maxScaledFloatPrecision,
+				db.DataTypeDecimal,
+			)
+		}
+
+		property := types.NewScaledFloatNumberProperty()
+		scalingFactor := types.Float64(math.Pow10(column.Scale))
+		property.ScalingFactor = &scalingFactor
+		return property, nil
 	default:
-		return nil, fmt.Errorf("unrecognized data type '%v'", dataType)
+		return nil, fmt.Errorf("unrecognized data type '%v'", column.DataType)
 	}
 }
 
+// The number of decimal digits that always fit in the 64-bit integers used by scaled_float.
+const maxScaledFloatPrecision = 18
+
 func sortOrderToElastic(sortOrder db.SortOrder) (elasticSortOrder sortorder.SortOrder, ok bool) {
 	switch sortOrder {
 	case db.SortOrderAscending:
@@ -58,6 +104,48 @@ func sortOrderToElastic(sortOrder db.SortOrder) (elasticSortOrder sortorder.Sort
 	}
 }
 
+// Returns the range query parameter for the given filter operator, if it is a range operator.
+// See https://www.elastic.co/guide/en/elasticsearch/reference/8.10/query-dsl-range-query.html
+func filterOperatorToElasticRange(operator db.FilterOperator) (rangeParameter string, ok bool) {
+	switch operator {
+	case db.FilterLessThan:
+		return "lt", true
+	case db.FilterLessThanOrEquals:
+		return "lte", true
+	case db.FilterGreaterThan:
+		return "gt", true
+	case db.FilterGreaterThanOrEquals:
+		return "gte", true
+	default:
+		return "", false
+	}
+}
+
+// Converts the given value to the format in which we store it in Elasticsearch (see
+// db.TableSchema.ConvertRowToMap).
+func valueToElastic(value db.DBValue, dataType db.DataType) any {
+	if datetime, isTime := value.Value().(time.Time); isTime {
+		if dataType == db.DataTypeDate {
+			return datetime.Format(db.DateFormat)
+		}
+		return datetime.UnixMilli()
+	}
+	return value.Value()
+}
+
+// Escapes characters with special meaning in Elasticsearch's regular expression syntax.
+// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/regexp-syntax.html#regexp-reserved-characters
+func escapeElasticRegex(value string) string {
+	var escaped strings.Builder
+	for _, char := range value {
+		if strings.ContainsRune(`.?+*|{}[]()"\#@&<>~`, char) {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(char)
+	}
+	return escaped.String()
+}
+
 func dateIntervalToElastic(
 	dateInterval db.DateInterval,
 ) (elasticDateInterval calendarinterval.CalendarInterval, ok bool) {
@@ -3,6 +3,7 @@ package elasticsearch
 import (
 	"context"
 	"errors"
+	"strings"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
@@ -49,9 +50,22 @@ func (elastic ElasticsearchDB) DropTable(
 	// See https://www.elastic.co/guide/en/elasticsearch/reference/8.10/troubleshooting-searches.html#troubleshooting-searches-exists
 	const elasticIndexNotFoundException = "index_not_found_exception"
 
-	if _, err := elastic.client.Indices.Delete(table).Do(ctx); err != nil {
+	isIndexNotFound := func(err error) bool {
 		elasticErr, isElasticErr := err.(*types.ElasticsearchError)
-		if isElasticErr && elasticErr.ErrorCause.Type == elasticIndexNotFoundException {
+		return isElasticErr && elasticErr.ErrorCause.Type == elasticIndexNotFoundException
+	}
+
+	// Indices can't be deleted through aliases, so we delete the table's backing indices
+	indices, err := elastic.getBackingIndices(ctx, table)
+	if err != nil {
+		if isIndexNotFound(err) {
+			return true, nil
+		}
+		return false, wrapElasticError(err, "failed to get Elasticsearch indices for table")
+	}
+
+	if _, err := elastic.client.Indices.Delete(strings.Join(indices, ",")).Do(ctx); err != nil {
+		if isIndexNotFound(err) {
 			return true, nil
 		}
 
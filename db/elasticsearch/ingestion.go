@@ -5,8 +5,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v8/esutil"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/reindex"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
 	"github.com/google/uuid"
 	"hermannm.dev/analysis/db"
 	"hermannm.dev/wrap"
@@ -18,7 +23,21 @@ func (elastic ElasticsearchDB) CreateTable(ctx context.Context, schema db.TableS
 		return wrap.Error(err, "failed to translate table schema to Elasticsearch mappings")
 	}
 
-	_, err = elastic.client.Indices.Create(schema.TableName).Mappings(mappings).Do(ctx)
+	// Creating an index with an alias that already exists would add the index to the alias, so we
+	// check that the table does not exist first
+	exists, err := elastic.client.Indices.Exists(schema.TableName).Do(ctx)
+	if err != nil {
+		return wrapElasticErrorf(err, "failed to check if table '%s' exists", schema.TableName)
+	}
+	if exists {
+		return fmt.Errorf("table '%s' already exists", schema.TableName)
+	}
+
+	_, err = elastic.client.Indices.
+		Create(newBackingIndexName(schema.TableName)).
+		Mappings(mappings).
+		Aliases(map[string]types.Alias{schema.TableName: {}}).
+		Do(ctx)
 	if err != nil {
 		return wrapElasticErrorf(
 			err,
@@ -30,22 +49,234 @@ func (elastic ElasticsearchDB) CreateTable(ctx context.Context, schema db.TableS
 	return nil
 }
 
+// Tables are aliases to backing indices, with one index per ingestion, so that IngestData can
+// atomically add new data to a table by adding a new index to its alias. Tables created before this
+// was introduced are plain indices named after the table, which IngestData turns into aliases on
+// their first ingestion.
+func newBackingIndexName(table string) string {
+	return table + "__" + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+// Returns the names of the indices that the given table's alias points to, or the table name
+// itself if the table is a plain index.
+func (elastic ElasticsearchDB) getBackingIndices(
+	ctx context.Context,
+	table string,
+) ([]string, error) {
+	response, err := elastic.client.Indices.Get(table).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	indices := make([]string, 0, len(response))
+	for index := range response {
+		indices = append(indices, index)
+	}
+	return indices, nil
+}
+
+// Ingests data all-or-nothing, by inserting it into a new index, and adding that index to the
+// table's alias once all rows are inserted. Searches on the alias go through all its indices, so
+// the data becomes part of the table at once. If ingestion fails, the new index is deleted, leaving
+// the table untouched. Concurrent ingestions into the same table each add their own index.
+//
+// Plain indices from before tables were aliases can't have an alias with the same name, so on their
+// first ingestion, we copy their documents into the new index, and delete the plain index in the
+// same request as creating the alias.
 func (elastic ElasticsearchDB) IngestData(
 	ctx context.Context,
 	data db.DataSource,
 	schema db.TableSchema,
+	options db.IngestionOptions,
+) (report db.IngestionReport, err error) {
+	table := schema.TableName
+
+	isAlias, err := elastic.client.Indices.ExistsAlias(table).Do(ctx)
+	if err != nil {
+		return db.IngestionReport{}, wrapElasticErrorf(
+			err,
+			"failed to check if table '%s' is an alias",
+			table,
+		)
+	}
+
+	mappings, err := schemaToElasticMappings(schema)
+	if err != nil {
+		return db.IngestionReport{}, wrap.Error(
+			err,
+			"failed to translate table schema to Elasticsearch mappings",
+		)
+	}
+
+	newIndex := newBackingIndexName(table)
+	_, err = elastic.client.Indices.Create(newIndex).Mappings(mappings).Do(ctx)
+	if err != nil {
+		return db.IngestionReport{}, wrapElasticErrorf(
+			err,
+			"failed to create new index for table '%s'",
+			table,
+		)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		// Deletes the new index even if the ingestion context was canceled
+		_, deleteErr := elastic.client.Indices.Delete(newIndex).Do(context.WithoutCancel(ctx))
+		if deleteErr != nil {
+			err = wrap.Errors(
+				"failed to ingest data AND failed to delete new index afterwards",
+				err,
+				formatElasticError(deleteErr),
+			)
+		}
+	}()
+
+	// See https://www.elastic.co/guide/en/elasticsearch/reference/8.10/indices-aliases.html
+	aliasActions := []types.IndicesAction{
+		{Add: &types.AddAction{Index: &newIndex, Alias: &table}},
+	}
+	if !isAlias {
+		if err := elastic.copyDocuments(ctx, table, newIndex); err != nil {
+			return db.IngestionReport{}, wrap.Errorf(
+				err,
+				"failed to copy documents from plain index '%s' to new index",
+				table,
+			)
+		}
+		aliasActions = append(
+			aliasActions,
+			types.IndicesAction{RemoveIndex: &types.RemoveIndexAction{Index: &table}},
+		)
+	}
+
+	report, err = elastic.bulkInsert(ctx, data, schema, newIndex, options)
+	if err != nil {
+		return report, err
+	}
+
+	// Makes the inserted documents searchable right away when the index is added to the alias
+	if _, err := elastic.client.Indices.Refresh().Index(newIndex).Do(ctx); err != nil {
+		return report, wrapElasticError(err, "failed to refresh new index")
+	}
+
+	_, err = elastic.client.Indices.UpdateAliases().Actions(aliasActions...).Do(ctx)
+	if err != nil {
+		return report, wrapElasticErrorf(
+			err,
+			"failed to add new index to table '%s'",
+			table,
+		)
+	}
+
+	return report, nil
+}
+
+// How often copyDocuments checks whether its reindex task has completed.
+const reindexPollInterval = time.Second
+
+// Copies all documents from the source index to the target index. Runs the reindex as a background
+// task in Elasticsearch, and polls it until it completes, so that copying large indices does not
+// time out like a single long-running request would.
+func (elastic ElasticsearchDB) copyDocuments(
+	ctx context.Context,
+	sourceIndex string,
+	targetIndex string,
 ) error {
+	// Reindexing only copies searchable documents, so we refresh the source index first, in case
+	// documents were recently inserted
+	if _, err := elastic.client.Indices.Refresh().Index(sourceIndex).Do(ctx); err != nil {
+		return wrapElasticError(err, "failed to refresh source index")
+	}
+
+	response, err := elastic.client.Reindex().
+		Source(&types.ReindexSource{Index: []string{sourceIndex}}).
+		Dest(&types.ReindexDestination{Index: targetIndex}).
+		WaitForCompletion(false).
+		Do(ctx)
+	if err != nil {
+		return wrapElasticError(err, "Elasticsearch reindex request failed")
+	}
+	taskID := fmt.Sprint(response.Task)
+
+	ticker := time.NewTicker(reindexPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Cancels the task even though the context was canceled, so it stops copying
+			cancelCtx := context.WithoutCancel(ctx)
+			if _, err := elastic.client.Tasks.Cancel().TaskId(taskID).Do(cancelCtx); err != nil {
+				return wrap.Errors(
+					"reindex was canceled AND failed to cancel reindex task",
+					ctx.Err(),
+					formatElasticError(err),
+				)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		task, err := elastic.client.Tasks.Get(taskID).Do(ctx)
+		if err != nil {
+			return wrapElasticError(err, "failed to get status of reindex task")
+		}
+		if !task.Completed {
+			continue
+		}
+
+		if task.Error != nil {
+			return formatElasticError(&types.ElasticsearchError{ErrorCause: *task.Error})
+		}
+		return checkReindexResult(task.Response)
+	}
+}
+
+func checkReindexResult(rawResult json.RawMessage) error {
+	var result reindex.Response
+	if err := json.Unmarshal(rawResult, &result); err != nil {
+		return wrap.Error(err, "failed to parse result of reindex task")
+	}
+
+	if len(result.Failures) != 0 {
+		failures := make([]error, len(result.Failures))
+		for i, failure := range result.Failures {
+			failures[i] = formatElasticError(
+				&types.ElasticsearchError{ErrorCause: failure.Cause, Status: failure.Status},
+			)
+		}
+		return wrap.Errors("failed to copy some documents", failures...)
+	}
+	if result.TimedOut != nil && *result.TimedOut {
+		//lint:ignore ST1005 Names in errors should still be capitalized
+		return errors.New("Elasticsearch reindex task timed out")
+	}
+
+	return nil
+}
+
+func (elastic ElasticsearchDB) bulkInsert(
+	ctx context.Context,
+	data db.DataSource,
+	schema db.TableSchema,
+	index string,
+	options db.IngestionOptions,
+) (db.IngestionReport, error) {
+	var report db.IngestionReport
+
 	ctx, cancel := context.WithCancelCause(ctx)
 
 	bulk, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
 		Client: elastic.untypedClient,
-		Index:  schema.TableName,
+		Index:  index,
 		OnError: func(ctx context.Context, err error) {
 			cancel(formatElasticError(extractElasticError(err)))
 		},
 	})
 	if err != nil {
-		return wrap.Error(err, "failed to prepare bulk data insert")
+		return report, wrap.Error(err, "failed to prepare bulk data insert")
 	}
 
 	for {
@@ -57,6 +288,7 @@ func (elastic ElasticsearchDB) IngestData(
 			cancel(wrap.Error(err, "failed to read row"))
 			break
 		}
+		options.Progress.AddRowRead()
 
 		id, err := uuid.NewUUID()
 		if err != nil {
@@ -67,12 +299,11 @@ func (elastic ElasticsearchDB) IngestData(
 
 		rowMap, err := schema.ConvertRowToMap(row)
 		if err != nil {
-			cancel(wrap.Errorf(
-				err,
-				"failed to convert row %d to data types expected by table schema",
-				rowNumber,
-			))
-			break
+			if err := report.RejectRow(err, rowNumber, options); err != nil {
+				cancel(err)
+				break
+			}
+			continue
 		}
 
 		rowJSON, err := json.Marshal(rowMap)
@@ -90,6 +321,13 @@ func (elastic ElasticsearchDB) IngestData(
 			Action:     "create",
 			DocumentID: idString,
 			Body:       bytes.NewReader(rowJSON),
+			OnSuccess: func(
+				context.Context,
+				esutil.BulkIndexerItem,
+				esutil.BulkIndexerResponseItem,
+			) {
+				options.Progress.AddRowsInserted(1)
+			},
 			OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, response esutil.BulkIndexerResponseItem, err error) {
 				cancel(wrap.Errorf(err, "failed to insert row %d", rowNumber))
 			},
@@ -101,17 +339,28 @@ func (elastic ElasticsearchDB) IngestData(
 		}
 	}
 
-	if err := bulk.Close(ctx); err != nil {
-		cause := context.Cause(ctx)
-		if cause == nil {
-			return wrap.Error(err, "failed to finish Elasticsearch bulk insert")
-		} else if cause == context.Canceled {
-			//lint:ignore ST1005 Names in errors should still be capitalized
-			return errors.New("Elasticsearch bulk insert was canceled before completion")
-		} else {
-			return wrap.Error(cause, "Elasticsearch bulk insert was canceled by error")
-		}
+	// Close returns nil if items fail in the final flush, after it has checked the context, so we
+	// also check the context's cause and the failure count afterwards
+	closeErr := bulk.Close(ctx)
+	cause := context.Cause(ctx)
+	switch {
+	case cause == context.Canceled:
+		//lint:ignore ST1005 Names in errors should still be capitalized
+		return report, errors.New("Elasticsearch bulk insert was canceled before completion")
+	case cause != nil:
+		return report, wrap.Error(cause, "Elasticsearch bulk insert was canceled by error")
+	case closeErr != nil:
+		return report, wrap.Error(closeErr, "failed to finish Elasticsearch bulk insert")
 	}
 
-	return nil
+	stats := bulk.Stats()
+	if stats.NumFailed > 0 {
+		return report, fmt.Errorf(
+			"failed to insert %d rows in Elasticsearch bulk insert",
+			stats.NumFailed,
+		)
+	}
+
+	report.InsertedRows = int(stats.NumCreated)
+	return report, nil
 }
@@ -0,0 +1,180 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"hermannm.dev/analysis/db"
+	"hermannm.dev/wrap"
+)
+
+type profileQueryResponse struct {
+	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+	} `json:"hits"`
+	// Maps aggregation names (from profileAggregationName) to their results. All the metric
+	// aggregations we use return a single value, which is null if there are no values. The missing
+	// aggregation instead returns a document count, and the terms aggregation returns buckets.
+	Aggregations map[string]struct {
+		Value    *float64              `json:"value"`
+		DocCount int64                 `json:"doc_count"`
+		Buckets  []distinctValueBucket `json:"buckets"`
+	} `json:"aggregations"`
+}
+
+// Aggregation names use the column index rather than the column name, to avoid clashes with
+// Elasticsearch's aggregation name syntax.
+func profileAggregationName(columnIndex int, metric string) string {
+	return fmt.Sprintf("column_%d_%s", columnIndex, metric)
+}
+
+func (elastic ElasticsearchDB) ProfileTable(
+	ctx context.Context,
+	schema db.TableSchema,
+) (db.TableProfile, error) {
+	aggregations := make(map[string]types.Aggregations, len(schema.Columns)*6)
+	for i, column := range schema.Columns {
+		field := column.Name
+
+		// Counts documents rather than values (like value_count does), since TAGS fields may have
+		// multiple values per document
+		// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/search-aggregations-bucket-missing-aggregation.html
+		aggregations[profileAggregationName(i, "missing")] = types.Aggregations{
+			Missing: &types.MissingAggregation{Field: &field},
+		}
+		if db.HasDistinctValues(column.DataType) {
+			// Cardinality is approximate for large numbers of distinct values
+			// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/search-aggregations-metrics-cardinality-aggregation.html
+			aggregations[profileAggregationName(i, "distinct")] = types.Aggregations{
+				Cardinality: &types.CardinalityAggregation{Field: &field},
+			}
+
+			// Gets top values in the same search, instead of a separate query per column
+			aggregations[profileAggregationName(i, "top")] = types.Aggregations{
+				Terms: createDistinctValuesAggregation(field, db.ProfileTopValuesLimit),
+			}
+		}
+
+		if db.HasMinMaxProfile(column.DataType) {
+			aggregations[profileAggregationName(i, "min")] = types.Aggregations{
+				Min: &types.MinAggregation{Field: &field},
+			}
+			aggregations[profileAggregationName(i, "max")] = types.Aggregations{
+				Max: &types.MaxAggregation{Field: &field},
+			}
+		}
+
+		if db.HasMeanProfile(column.DataType) {
+			aggregations[profileAggregationName(i, "avg")] = types.Aggregations{
+				Avg: &types.AverageAggregation{Field: &field},
+			}
+		}
+	}
+
+	query := elastic.client.Search().
+		Index(schema.TableName).
+		Aggregations(aggregations).
+		TrackTotalHits(true).
+		Size(0)
+
+	response, err := executeSearch[profileQueryResponse](ctx, query)
+	if err != nil {
+		return db.TableProfile{}, wrapElasticError(err, "failed to execute profiling query")
+	}
+
+	profile, err := parseProfileQueryResponse(response, schema)
+	if err != nil {
+		return db.TableProfile{}, wrap.Error(err, "failed to parse profiling query result")
+	}
+
+	return profile, nil
+}
+
+func parseProfileQueryResponse(
+	response profileQueryResponse,
+	schema db.TableSchema,
+) (db.TableProfile, error) {
+	profile := db.TableProfile{
+		TableName: schema.TableName,
+		RowCount:  response.Hits.Total.Value,
+		Columns:   make([]db.ColumnProfile, len(schema.Columns)),
+	}
+
+	for i, column := range schema.Columns {
+		columnProfile := db.NewColumnProfile(column)
+		aggregationValue := func(metric string) *float64 {
+			return response.Aggregations[profileAggregationName(i, metric)].Value
+		}
+
+		missing := response.Aggregations[profileAggregationName(i, "missing")]
+		columnProfile.NullCount = missing.DocCount
+		if distinct := aggregationValue("distinct"); distinct != nil {
+			columnProfile.DistinctCount = int64(*distinct)
+		}
+
+		if db.HasMinMaxProfile(column.DataType) {
+			var err error
+			columnProfile.Min, err = parseProfileValue(aggregationValue("min"), column.DataType)
+			if err != nil {
+				return db.TableProfile{}, wrap.Errorf(
+					err,
+					"invalid min value for column '%s'",
+					column.Name,
+				)
+			}
+
+			columnProfile.Max, err = parseProfileValue(aggregationValue("max"), column.DataType)
+			if err != nil {
+				return db.TableProfile{}, wrap.Errorf(
+					err,
+					"invalid max value for column '%s'",
+					column.Name,
+				)
+			}
+		}
+
+		if db.HasMeanProfile(column.DataType) {
+			columnProfile.Mean = aggregationValue("avg")
+		}
+
+		if db.HasDistinctValues(column.DataType) {
+			var err error
+			columnProfile.TopValues, err = parseDistinctValueBuckets(
+				response.Aggregations[profileAggregationName(i, "top")].Buckets,
+				column.DataType.ValueDataType(),
+			)
+			if err != nil {
+				return db.TableProfile{}, wrap.Errorf(
+					err,
+					"invalid top values for column '%s'",
+					column.Name,
+				)
+			}
+		}
+
+		profile.Columns[i] = columnProfile
+	}
+
+	return profile, nil
+}
+
+// Returns nil if the given value is nil, i.e. the column had no non-NULL values.
+func parseProfileValue(value *float64, dataType db.DataType) (db.DBValue, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	dbValue, err := db.NewDBValue(dataType)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setResultValue(dbValue, *value, dataType); err != nil {
+		return nil, err
+	}
+
+	return dbValue, nil
+}
@@ -0,0 +1,349 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/sortorder"
+	"hermannm.dev/analysis/db"
+	"hermannm.dev/wrap"
+)
+
+// Elasticsearch paginates with search_after on a point in time (PIT), which keeps a consistent view
+// of the index between pages. When searching on a PIT, Elasticsearch adds an implicit tiebreaker
+// sort on the document's shard and position, so page boundaries are stable.
+// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/paginate-search-results.html#search-after
+type rowsCursor struct {
+	PointInTimeID string            `json:"pitId"`
+	SearchAfter   []json.RawMessage `json:"searchAfter"`
+}
+
+func (cursor *rowsCursor) Validate() error {
+	if cursor.PointInTimeID == "" {
+		return errors.New("missing point in time ID")
+	}
+	return nil
+}
+
+// How long Elasticsearch should keep a point in time open between page requests. PITs are not
+// closed explicitly, as clients may stop paginating at any point - instead, they expire after this
+// duration.
+const pointInTimeKeepAlive = "5m"
+
+type rowsQueryResponse struct {
+	PointInTimeID string `json:"pit_id"`
+	Hits          struct {
+		Hits []struct {
+			Source map[string]any `json:"_source"`
+			// Kept as raw JSON, to not lose precision on large integers when passing them back in
+			// search_after
+			Sort []json.RawMessage `json:"sort"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+func (elastic ElasticsearchDB) QueryRows(
+	ctx context.Context,
+	rowsQuery db.RowsQuery,
+	schema db.TableSchema,
+) (db.RowsResult, error) {
+	columns, err := rowsQuery.Validate(schema)
+	if err != nil {
+		return db.RowsResult{}, wrap.Error(err, "invalid rows query")
+	}
+
+	var cursor rowsCursor
+	if rowsQuery.Cursor == "" {
+		pointInTime, err := elastic.client.OpenPointInTime(schema.TableName).
+			KeepAlive(pointInTimeKeepAlive).
+			Do(ctx)
+		if err != nil {
+			return db.RowsResult{}, wrapElasticError(err, "failed to open point in time")
+		}
+		cursor.PointInTimeID = pointInTime.Id
+	} else {
+		if err := db.DecodeCursor(rowsQuery.Cursor, &cursor); err != nil {
+			return db.RowsResult{}, err
+		}
+	}
+
+	query, err := elastic.translateRowsQuery(rowsQuery, columns, schema, cursor)
+	if err != nil {
+		return db.RowsResult{}, wrap.Error(err, "failed to parse rows query")
+	}
+
+	response, err := executeSearch[rowsQueryResponse](ctx, query)
+	if err != nil {
+		return db.RowsResult{}, wrapElasticError(err, "failed to execute rows query")
+	}
+
+	result, err := parseRowsQueryResponse(response, columns, rowsQuery.Limit)
+	if err != nil {
+		return db.RowsResult{}, wrap.Error(err, "failed to parse rows query result")
+	}
+
+	if len(response.Hits.Hits) > rowsQuery.Limit {
+		lastHit := response.Hits.Hits[rowsQuery.Limit-1]
+
+		// Elasticsearch may update the PIT ID between requests, so we use the latest one
+		// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/point-in-time-api.html
+		pointInTimeID := response.PointInTimeID
+		if pointInTimeID == "" {
+			pointInTimeID = cursor.PointInTimeID
+		}
+
+		result.NextCursor, err = db.EncodeCursor(
+			rowsCursor{PointInTimeID: pointInTimeID, SearchAfter: lastHit.Sort},
+		)
+		if err != nil {
+			return db.RowsResult{}, err
+		}
+	}
+
+	return result, nil
+}
+
+func (elastic ElasticsearchDB) translateRowsQuery(
+	rowsQuery db.RowsQuery,
+	columns []db.Column,
+	schema db.TableSchema,
+	cursor rowsCursor,
+) (*search.Search, error) {
+	filterQuery, err := createFilterQuery(rowsQuery.Filters, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	sorts := make([]types.SortCombinations, 0, len(rowsQuery.SortBy))
+	for _, sort := range rowsQuery.SortBy {
+		sortOrder, ok := sortOrderToElastic(sort.SortOrder)
+		if !ok {
+			return nil, fmt.Errorf("invalid sort order '%v'", sort.SortOrder)
+		}
+
+		sorts = append(sorts, types.SortOptions{
+			SortOptions: map[string]types.FieldSort{sort.FieldName: {Order: &sortOrder}},
+		})
+	}
+
+	columnNames := make([]string, len(columns))
+	for i, column := range columns {
+		columnNames[i] = column.Name
+	}
+
+	// Searches on a PIT must not specify an index, as the PIT is already bound to one
+	query := elastic.client.Search().
+		Pit(&types.PointInTimeReference{
+			Id:        cursor.PointInTimeID,
+			KeepAlive: pointInTimeKeepAlive,
+		}).
+		Query(filterQuery).
+		Sort(sorts...).
+		Source_(types.SourceFilter{Includes: columnNames}).
+		// Fetches one more row than the limit, to know if there is a next page
+		Size(rowsQuery.Limit + 1)
+
+	if len(cursor.SearchAfter) != 0 {
+		searchAfter := make([]types.FieldValue, len(cursor.SearchAfter))
+		for i, value := range cursor.SearchAfter {
+			searchAfter[i] = value
+		}
+		query.SearchAfter(searchAfter...)
+	}
+
+	return query, nil
+}
+
+func createFilterQuery(filters []db.Filter, schema db.TableSchema) (*types.Query, error) {
+	boolQuery := types.NewBoolQuery()
+
+	for _, filter := range filters {
+		column, ok := schema.GetColumn(filter.FieldName)
+		if !ok {
+			return nil, fmt.Errorf("filter column '%s' not found in table", filter.FieldName)
+		}
+
+		value, err := filter.ParseValue(column.DataType)
+		if err != nil {
+			return nil, wrap.Errorf(err, "invalid filter on column '%s'", filter.FieldName)
+		}
+
+		var elasticValue any
+		if value != nil { // Value is nil for IS_NULL/IS_NOT_NULL
+			elasticValue = valueToElastic(value, column.DataType)
+		}
+
+		field := filter.FieldName
+		exists := types.Query{Exists: &types.ExistsQuery{Field: field}}
+
+		switch filter.Operator {
+		case db.FilterEquals:
+			boolQuery.Filter = append(boolQuery.Filter, types.Query{
+				Term: map[string]types.TermQuery{field: {Value: elasticValue}},
+			})
+		case db.FilterNotEquals:
+			// Requires the field to exist, to match the SQL semantics of NULL != x being false.
+			// TAGS columns are never NULL (rows without tags have no tags, rather than NULL), so
+			// rows without tags should still match.
+			if column.DataType != db.DataTypeTags {
+				boolQuery.Filter = append(boolQuery.Filter, exists)
+			}
+			boolQuery.MustNot = append(boolQuery.MustNot, types.Query{
+				Term: map[string]types.TermQuery{field: {Value: elasticValue}},
+			})
+		case db.FilterIsNull:
+			boolQuery.MustNot = append(boolQuery.MustNot, exists)
+		case db.FilterIsNotNull:
+			boolQuery.Filter = append(boolQuery.Filter, exists)
+		default:
+			rangeParameter, ok := filterOperatorToElasticRange(filter.Operator)
+			if !ok {
+				return nil, fmt.Errorf("unrecognized filter operator '%v'", filter.Operator)
+			}
+
+			boolQuery.Filter = append(boolQuery.Filter, types.Query{
+				Range: map[string]types.RangeQuery{
+					field: map[string]any{rangeParameter: elasticValue},
+				},
+			})
+		}
+	}
+
+	return &types.Query{Bool: boolQuery}, nil
+}
+
+func parseRowsQueryResponse(
+	response rowsQueryResponse,
+	columns []db.Column,
+	limit int,
+) (db.RowsResult, error) {
+	hits := response.Hits.Hits
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	result := db.RowsResult{Columns: columns, Rows: make([][]db.DBValue, 0, len(hits))}
+
+	for _, hit := range hits {
+		row := make([]db.DBValue, len(columns))
+
+		for i, column := range columns {
+			rawValue, ok := hit.Source[column.Name]
+			if !ok || rawValue == nil {
+				continue // Leaves NULL values as nil
+			}
+
+			value, err := db.NewDBValue(column.DataType)
+			if err != nil {
+				return db.RowsResult{}, wrap.Errorf(
+					err,
+					"failed to initialize value for column '%s'",
+					column.Name,
+				)
+			}
+
+			if err := setResultValue(value, rawValue, column.DataType); err != nil {
+				return db.RowsResult{}, wrap.Errorf(
+					err,
+					"failed to set value for column '%s'",
+					column.Name,
+				)
+			}
+
+			row[i] = value
+		}
+
+		result.Rows = append(result.Rows, row)
+	}
+
+	return result, nil
+}
+
+const distinctValuesName = "distinct_values"
+
+type distinctValuesResponse struct {
+	Aggregations struct {
+		DistinctValues struct {
+			Buckets []distinctValueBucket `json:"buckets"`
+		} `json:"distinct_values"`
+	} `json:"aggregations"`
+}
+
+type distinctValueBucket struct {
+	Key      any   `json:"key"`
+	DocCount int64 `json:"doc_count"`
+}
+
+func (elastic ElasticsearchDB) QueryDistinctValues(
+	ctx context.Context,
+	valuesQuery db.DistinctValuesQuery,
+	schema db.TableSchema,
+) ([]db.DistinctValue, error) {
+	column, err := valuesQuery.Validate(schema)
+	if err != nil {
+		return nil, wrap.Error(err, "invalid distinct values query")
+	}
+
+	terms := createDistinctValuesAggregation(column.Name, valuesQuery.Limit)
+	if valuesQuery.SearchPrefix != "" {
+		// Include takes a regular expression, so we escape the prefix before matching any suffix
+		// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/search-aggregations-bucket-terms-aggregation.html#_filtering_values_with_regular_expressions_2
+		terms.Include = escapeElasticRegex(valuesQuery.SearchPrefix) + ".*"
+	}
+
+	query := elastic.client.Search().
+		Index(schema.TableName).
+		Aggregations(map[string]types.Aggregations{distinctValuesName: {Terms: terms}}).
+		Size(0)
+
+	response, err := executeSearch[distinctValuesResponse](ctx, query)
+	if err != nil {
+		return nil, wrapElasticError(err, "failed to execute distinct values query")
+	}
+
+	return parseDistinctValueBuckets(
+		response.Aggregations.DistinctValues.Buckets,
+		column.DataType.ValueDataType(),
+	)
+}
+
+// Creates a terms aggregation for the most frequent values of the given field, ordered by count
+// and then by value.
+func createDistinctValuesAggregation(field string, limit int) *types.TermsAggregation {
+	// See createSplit for why we increase the shard size
+	shardSize := limit*10 + 100
+	return &types.TermsAggregation{
+		Field:     &field,
+		Size:      &limit,
+		ShardSize: &shardSize,
+		Order: []map[string]sortorder.SortOrder{
+			{"_count": sortorder.Desc},
+			{"_key": sortorder.Asc},
+		},
+	}
+}
+
+func parseDistinctValueBuckets(
+	buckets []distinctValueBucket,
+	valueDataType db.DataType,
+) ([]db.DistinctValue, error) {
+	values := make([]db.DistinctValue, 0, len(buckets))
+	for _, bucket := range buckets {
+		value, err := db.NewDBValue(valueDataType)
+		if err != nil {
+			return nil, wrap.Error(err, "failed to initialize distinct value")
+		}
+
+		if err := setResultValue(value, bucket.Key, valueDataType); err != nil {
+			return nil, wrap.Error(err, "failed to set distinct value")
+		}
+
+		values = append(values, db.DistinctValue{Value: value, Count: bucket.DocCount})
+	}
+
+	return values, nil
+}
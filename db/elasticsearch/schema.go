@@ -11,13 +11,30 @@ import (
 
 func (elastic ElasticsearchDB) CreateStoredSchemasTable(ctx context.Context) error {
 	mappings := new(types.TypeMapping)
-	mappings.Properties = make(map[string]types.Property, 4)
+	mappings.Properties = make(map[string]types.Property, 14)
 
 	// Array fields in Elasticsearch don't have their own mapping: any field can contain multiple
 	// values of that type (see https://www.elastic.co/guide/en/elasticsearch/reference/8.10/array.html).
 	mappings.Properties[db.StoredSchemaColumnNames] = types.NewTextProperty()
 	mappings.Properties[db.StoredSchemaColumnDataTypes] = types.NewByteNumberProperty()
 	mappings.Properties[db.StoredSchemaColumnOptionals] = types.NewBooleanProperty()
+	mappings.Properties[db.StoredSchemaColumnPrecisions] = types.NewByteNumberProperty()
+	mappings.Properties[db.StoredSchemaColumnScales] = types.NewByteNumberProperty()
+	mappings.Properties[db.StoredSchemaColumnTagSeparators] = types.NewKeywordProperty()
+	mappings.Properties[db.StoredSchemaColumnLongitudeColumns] = types.NewKeywordProperty()
+	mappings.Properties[db.StoredSchemaColumnDateTimeFormats] = types.NewKeywordProperty()
+	mappings.Properties[db.StoredSchemaColumnLabels] = types.NewTextProperty()
+	mappings.Properties[db.StoredSchemaColumnDescriptions] = types.NewTextProperty()
+	mappings.Properties[db.StoredSchemaColumnUnits] = types.NewKeywordProperty()
+
+	// Parse options and number formats are stored as JSON strings, which we never search on
+	indexed := false
+	parseOptionsProperty := types.NewKeywordProperty()
+	parseOptionsProperty.Index = &indexed
+	mappings.Properties[db.StoredSchemaParseOptions] = parseOptionsProperty
+	numberFormatsProperty := types.NewKeywordProperty()
+	numberFormatsProperty.Index = &indexed
+	mappings.Properties[db.StoredSchemaColumnNumberFormats] = numberFormatsProperty
 
 	const elasticResourceAlreadyExistsException = "resource_already_exists_exception"
 
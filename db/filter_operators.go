@@ -0,0 +1,58 @@
+package db
+
+import "hermannm.dev/enumnames"
+
+type FilterOperator int8
+
+const (
+	FilterEquals FilterOperator = iota + 1
+	FilterNotEquals
+	FilterLessThan
+	FilterLessThanOrEquals
+	FilterGreaterThan
+	FilterGreaterThanOrEquals
+	FilterIsNull
+	FilterIsNotNull
+)
+
+var filterOperatorMap = enumnames.NewMap(map[FilterOperator]string{
+	FilterEquals:              "EQUALS",
+	FilterNotEquals:           "NOT_EQUALS",
+	FilterLessThan:            "LESS_THAN",
+	FilterLessThanOrEquals:    "LESS_THAN_OR_EQUALS",
+	FilterGreaterThan:         "GREATER_THAN",
+	FilterGreaterThanOrEquals: "GREATER_THAN_OR_EQUALS",
+	FilterIsNull:              "IS_NULL",
+	FilterIsNotNull:           "IS_NOT_NULL",
+})
+
+func (operator FilterOperator) IsValid() bool {
+	return filterOperatorMap.ContainsKey(operator)
+}
+
+// Returns true for operators that check for NULL, and so take no filter value.
+func (operator FilterOperator) IsNullCheck() bool {
+	return operator == FilterIsNull || operator == FilterIsNotNull
+}
+
+// Returns true for the less than/greater than operators.
+func (operator FilterOperator) IsRangeComparison() bool {
+	switch operator {
+	case FilterLessThan, FilterLessThanOrEquals, FilterGreaterThan, FilterGreaterThanOrEquals:
+		return true
+	default:
+		return false
+	}
+}
+
+func (operator FilterOperator) String() string {
+	return filterOperatorMap.GetNameOrFallback(operator, "INVALID_FILTER_OPERATOR")
+}
+
+func (operator FilterOperator) MarshalJSON() ([]byte, error) {
+	return filterOperatorMap.MarshalToNameJSON(operator)
+}
+
+func (operator *FilterOperator) UnmarshalJSON(bytes []byte) error {
+	return filterOperatorMap.UnmarshalFromNameJSON(bytes, operator)
+}
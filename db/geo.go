@@ -0,0 +1,136 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+)
+
+const (
+	// Geohash precisions are the number of characters in the geohash, where 12 is the maximum
+	// supported by both ClickHouse and Elasticsearch.
+	// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/search-aggregations-bucket-geohashgrid-aggregation.html#_cell_dimensions_at_the_equator
+	MaxGeohashPrecision = 12
+	// Zoom levels of geotiles, which follow the map tile scheme used by web maps.
+	// https://www.elastic.co/guide/en/elasticsearch/reference/8.10/search-aggregations-bucket-geotilegrid-aggregation.html
+	MaxGeotileZoom = 29
+)
+
+// Checks that GeohashPrecision/GeotileZoom are valid for the split's data type.
+func (split Split) ValidateGeoGrid() error {
+	if split.DataType != DataTypeGeoPoint {
+		if split.GeohashPrecision != 0 || split.GeotileZoom != 0 {
+			return fmt.Errorf(
+				"geohash precision and geotile zoom can only be set for %v splits",
+				DataTypeGeoPoint,
+			)
+		}
+		return nil
+	}
+
+	switch {
+	case split.GeohashPrecision != 0 && split.GeotileZoom != 0:
+		return errors.New("only one of geohash precision and geotile zoom can be set")
+	case split.GeohashPrecision != 0:
+		if split.GeohashPrecision < 1 || split.GeohashPrecision > MaxGeohashPrecision {
+			return fmt.Errorf("geohash precision must be between 1 and %d", MaxGeohashPrecision)
+		}
+	case split.GeotileZoom != 0:
+		if split.GeotileZoom < 1 || split.GeotileZoom > MaxGeotileZoom {
+			return fmt.Errorf("geotile zoom must be between 1 and %d", MaxGeotileZoom)
+		}
+	default:
+		return fmt.Errorf(
+			"%v splits must set either geohash precision or geotile zoom",
+			DataTypeGeoPoint,
+		)
+	}
+
+	return nil
+}
+
+// Parses a GEO_POINT field in the format "latitude,longitude". Returns the point as an orb.Point,
+// which is in [longitude, latitude] order. Both ClickHouse and Elasticsearch accept orb.Point:
+// ClickHouse maps it to its Point type, and it encodes to a JSON array in the GeoJSON order that
+// Elasticsearch expects.
+func parseGeoPoint(field string) (orb.Point, error) {
+	latitudeString, longitudeString, found := strings.Cut(field, ",")
+	if !found {
+		return orb.Point{}, errors.New("expected geo point in format 'latitude,longitude'")
+	}
+
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(latitudeString), 64)
+	if err != nil {
+		return orb.Point{}, fmt.Errorf("invalid latitude '%s'", latitudeString)
+	}
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(longitudeString), 64)
+	if err != nil {
+		return orb.Point{}, fmt.Errorf("invalid longitude '%s'", longitudeString)
+	}
+
+	if latitude < -90 || latitude > 90 {
+		return orb.Point{}, fmt.Errorf("latitude %v is not between -90 and 90", latitude)
+	}
+	if longitude < -180 || longitude > 180 {
+		return orb.Point{}, fmt.Errorf("longitude %v is not between -180 and 180", longitude)
+	}
+
+	return orb.Point{longitude, latitude}, nil
+}
+
+// Only deduces fields as GEO_POINT if both coordinates have decimals, as fields such as "1,5" are
+// more likely to be something else (such as numbers with decimal commas).
+func isGeoPointField(field string) bool {
+	latitude, longitude, found := strings.Cut(field, ",")
+	if !found || !strings.Contains(latitude, ".") || !strings.Contains(longitude, ".") {
+		return false
+	}
+
+	_, err := parseGeoPoint(field)
+	return err == nil
+}
+
+// Returns the field to convert for the column at the given index in the given row, normalized with
+// the schema's ParseOptions. For GEO_POINT columns with a LongitudeColumn, this joins the column's
+// latitude field with the longitude field into the format expected by parseGeoPoint.
+func (schema TableSchema) fieldForColumn(rawRow []string, columnIndex int) (string, error) {
+	column := schema.Columns[columnIndex]
+	field := schema.ParseOptions.normalizeField(rawRow[columnIndex])
+
+	if column.DataType != DataTypeGeoPoint || column.LongitudeColumn == "" {
+		return field, nil
+	}
+
+	longitudeIndex := schema.getColumnIndex(column.LongitudeColumn)
+	if longitudeIndex == -1 {
+		return "", fmt.Errorf("longitude column '%s' not found", column.LongitudeColumn)
+	}
+	longitude := schema.ParseOptions.normalizeField(rawRow[longitudeIndex])
+
+	// If both are blank, we leave the field blank so that it becomes NULL
+	if field == "" && longitude == "" {
+		return "", nil
+	}
+	return field + "," + longitude, nil
+}
+
+func (schema TableSchema) validateLongitudeColumn(column Column) error {
+	if column.LongitudeColumn == "" {
+		return nil
+	}
+
+	if column.DataType != DataTypeGeoPoint {
+		return fmt.Errorf("longitude column can only be set on %v columns", DataTypeGeoPoint)
+	}
+	if column.LongitudeColumn == column.Name {
+		return errors.New("longitude column cannot be the column itself")
+	}
+	if schema.getColumnIndex(column.LongitudeColumn) == -1 {
+		return fmt.Errorf("longitude column '%s' not found in table", column.LongitudeColumn)
+	}
+
+	return nil
+}
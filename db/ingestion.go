@@ -0,0 +1,195 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"hermannm.dev/enumnames"
+	"hermannm.dev/wrap"
+)
+
+type IngestionMode int8
+
+const (
+	// Aborts ingestion on the first row that fails to convert to the table schema.
+	IngestionModeStrict IngestionMode = iota + 1
+	// Skips rows that fail to convert to the table schema, and reports them in IngestionReport, up
+	// to IngestionOptions.MaxRejectedRows.
+	IngestionModeLenient
+)
+
+var ingestionModeMap = enumnames.NewMap(map[IngestionMode]string{
+	IngestionModeStrict:  "STRICT",
+	IngestionModeLenient: "LENIENT",
+})
+
+func (mode IngestionMode) IsValid() bool {
+	return ingestionModeMap.ContainsKey(mode)
+}
+
+func (mode IngestionMode) String() string {
+	return ingestionModeMap.GetNameOrFallback(mode, "INVALID_INGESTION_MODE")
+}
+
+func (mode IngestionMode) MarshalJSON() ([]byte, error) {
+	return ingestionModeMap.MarshalToNameJSON(mode)
+}
+
+func (mode *IngestionMode) UnmarshalJSON(bytes []byte) error {
+	return ingestionModeMap.UnmarshalFromNameJSON(bytes, mode)
+}
+
+type IngestionOptions struct {
+	// Defaults to IngestionModeStrict.
+	Mode IngestionMode `json:"mode,omitempty"`
+	// In IngestionModeLenient, ingestion fails if more rows than this are rejected. Defaults to
+	// DefaultMaxRejectedRows.
+	MaxRejectedRows int `json:"maxRejectedRows,omitempty"`
+	// If set, this is updated as rows are read, inserted and rejected, so that ingestion progress
+	// can be tracked from other goroutines.
+	Progress *IngestionProgress `json:"-"`
+}
+
+const (
+	DefaultMaxRejectedRows = 1000
+	// Limits the size of IngestionReport, since rejected rows are kept in memory.
+	MaxMaxRejectedRows = 100000
+)
+
+func (options IngestionOptions) Validate() error {
+	if options.Mode != 0 && !options.Mode.IsValid() {
+		return errors.New("invalid ingestion mode")
+	}
+	if options.MaxRejectedRows < 0 {
+		return errors.New("max rejected rows cannot be negative")
+	}
+	if options.MaxRejectedRows > MaxMaxRejectedRows {
+		return fmt.Errorf("max rejected rows cannot be more than %d", MaxMaxRejectedRows)
+	}
+	return nil
+}
+
+func (options IngestionOptions) maxRejectedRows() int {
+	if options.MaxRejectedRows == 0 {
+		return DefaultMaxRejectedRows
+	}
+	return options.MaxRejectedRows
+}
+
+// Counts of rows processed by an ongoing ingestion. Safe for concurrent use. Methods that update
+// the counts are no-ops on nil, so ingestion does not need to check whether progress is tracked.
+type IngestionProgress struct {
+	rowsRead     atomic.Int64
+	rowsInserted atomic.Int64
+	rowsRejected atomic.Int64
+}
+
+func (progress *IngestionProgress) RowsRead() int64 {
+	return progress.rowsRead.Load()
+}
+
+// Rows are inserted in batches, so this lags behind RowsRead.
+func (progress *IngestionProgress) RowsInserted() int64 {
+	return progress.rowsInserted.Load()
+}
+
+func (progress *IngestionProgress) RowsRejected() int64 {
+	return progress.rowsRejected.Load()
+}
+
+func (progress *IngestionProgress) AddRowRead() {
+	if progress != nil {
+		progress.rowsRead.Add(1)
+	}
+}
+
+func (progress *IngestionProgress) AddRowsInserted(count int) {
+	if progress != nil {
+		progress.rowsInserted.Add(int64(count))
+	}
+}
+
+type IngestionReport struct {
+	InsertedRows int `json:"insertedRows"`
+	// Rows that were skipped in IngestionModeLenient, in the order they were read.
+	RejectedRows []RejectedRow `json:"rejectedRows"`
+}
+
+type RejectedRow struct {
+	RowNumber int `json:"rowNumber"`
+	// Blank if the row as a whole was invalid, such as when it has the wrong number of fields.
+	ColumnName string `json:"columnName,omitempty"`
+	// The field that failed to convert, as it was written in the data source.
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// Handles an error from converting a row to the table schema during ingestion. In
+// IngestionModeLenient, the row is added to the report, and nil is returned so the caller can skip
+// the row. Otherwise, or if the row would exceed the options' max rejected rows, the error is
+// returned.
+func (report *IngestionReport) RejectRow(
+	conversionErr error,
+	rowNumber int,
+	options IngestionOptions,
+) error {
+	if options.Mode != IngestionModeLenient {
+		return wrap.Errorf(
+			conversionErr,
+			"failed to convert row %d to data types expected by table schema",
+			rowNumber,
+		)
+	}
+
+	if len(report.RejectedRows) >= options.maxRejectedRows() {
+		return wrap.Errorf(
+			conversionErr,
+			"failed to convert row %d to data types expected by table schema "+
+				"(exceeded limit of %d rejected rows)",
+			rowNumber,
+			options.maxRejectedRows(),
+		)
+	}
+
+	rejectedRow := RejectedRow{RowNumber: rowNumber}
+
+	var fieldErr FieldConversionError
+	if errors.As(conversionErr, &fieldErr) {
+		rejectedRow.ColumnName = fieldErr.ColumnName
+		rejectedRow.Value = fieldErr.Value
+		rejectedRow.Reason = fieldErr.Err.Error()
+	} else {
+		rejectedRow.Reason = conversionErr.Error()
+	}
+
+	report.RejectedRows = append(report.RejectedRows, rejectedRow)
+	if options.Progress != nil {
+		options.Progress.rowsRejected.Add(1)
+	}
+	return nil
+}
+
+// Returned by TableSchema.ConvertRowToMap and TableSchema.ConvertAndAppendRow when a field fails
+// to convert to its column's data type.
+type FieldConversionError struct {
+	ColumnName string
+	DataType   DataType
+	// The raw field from the row that was converted.
+	Value string
+	Err   error
+}
+
+func (err FieldConversionError) Error() string {
+	return wrap.Errorf(
+		err.Err,
+		"failed to convert field '%s' to %s for column '%s'",
+		err.Value,
+		err.DataType,
+		err.ColumnName,
+	).Error()
+}
+
+func (err FieldConversionError) Unwrap() error {
+	return err.Err
+}
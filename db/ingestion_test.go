@@ -0,0 +1,119 @@
+package db
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRejectRow(t *testing.T) {
+	schema := TableSchema{
+		Columns: []Column{
+			{Name: "id", DataType: DataTypeInt},
+			{Name: "name", DataType: DataTypeText},
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		rows             [][]string
+		options          IngestionOptions
+		wantErr          bool
+		wantRejectedRows []RejectedRow
+	}{
+		{
+			name:    "Strict",
+			rows:    [][]string{{"1"}},
+			options: IngestionOptions{Mode: IngestionModeStrict},
+			wantErr: true,
+		},
+		{
+			name:    "Strict by default",
+			rows:    [][]string{{"x", "apple"}},
+			options: IngestionOptions{},
+			wantErr: true,
+		},
+		{
+			name:    "Lenient with short row",
+			rows:    [][]string{{"1"}},
+			options: IngestionOptions{Mode: IngestionModeLenient},
+			wantRejectedRows: []RejectedRow{
+				{RowNumber: 1, Reason: "wrong number of fields: expected 2, got 1"},
+			},
+		},
+		{
+			name:    "Lenient with long row",
+			rows:    [][]string{{"1", "apple", "pear"}},
+			options: IngestionOptions{Mode: IngestionModeLenient},
+			wantRejectedRows: []RejectedRow{
+				{RowNumber: 1, Reason: "wrong number of fields: expected 2, got 3"},
+			},
+		},
+		{
+			name:    "Lenient with invalid field",
+			rows:    [][]string{{"1", "apple"}, {"x", "pear"}},
+			options: IngestionOptions{Mode: IngestionModeLenient},
+			wantRejectedRows: []RejectedRow{
+				{RowNumber: 2, ColumnName: "id", Value: "x"},
+			},
+		},
+		{
+			name:    "Lenient within max rejected rows",
+			rows:    [][]string{{"x", "apple"}, {"2"}},
+			options: IngestionOptions{Mode: IngestionModeLenient, MaxRejectedRows: 2},
+			wantRejectedRows: []RejectedRow{
+				{RowNumber: 1, ColumnName: "id", Value: "x"},
+				{RowNumber: 2, Reason: "wrong number of fields: expected 2, got 1"},
+			},
+		},
+		{
+			name:    "Lenient exceeding max rejected rows",
+			rows:    [][]string{{"x", "apple"}, {"2"}, {"3", "pear"}, {"y", "plum"}},
+			options: IngestionOptions{Mode: IngestionModeLenient, MaxRejectedRows: 2},
+			wantErr: true,
+			wantRejectedRows: []RejectedRow{
+				{RowNumber: 1, ColumnName: "id", Value: "x"},
+				{RowNumber: 2, Reason: "wrong number of fields: expected 2, got 1"},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var report IngestionReport
+			var err error
+			for i, row := range testCase.rows {
+				_, conversionErr := schema.ConvertRowToMap(row)
+				if conversionErr == nil {
+					continue
+				}
+
+				err = report.RejectRow(conversionErr, i+1, testCase.options)
+				if err != nil {
+					break
+				}
+			}
+
+			if testCase.wantErr && err == nil {
+				t.Error("expected error, got nil")
+			} else if !testCase.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			// Field conversion reasons come from the parsing functions, so we only check reasons
+			// for rows that were rejected as a whole
+			rejectedRows := slices.Clone(report.RejectedRows)
+			for i, rejectedRow := range rejectedRows {
+				if rejectedRow.ColumnName != "" {
+					rejectedRows[i].Reason = ""
+				}
+			}
+			if !slices.Equal(rejectedRows, testCase.wantRejectedRows) {
+				t.Errorf(
+					"expected rejected rows %+v, got %+v",
+					testCase.wantRejectedRows,
+					report.RejectedRows,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Formatting of numbers in INTEGER, FLOAT and DECIMAL columns, for numbers written in other ways
+// than Go's strconv package expects, such as "1 234,56" or "kr 1,234.56".
+type NumberFormat struct {
+	// Separator between the integer and fractional parts of numbers. If blank, "." is used.
+	DecimalSeparator string `json:"decimalSeparator,omitempty"`
+	// Separator between groups of 3 digits in the integer part of numbers. If blank, digits are
+	// not grouped. If " ", non-breaking spaces are also accepted, as spreadsheet programs often use
+	// them for grouping.
+	ThousandsSeparator string `json:"thousandsSeparator,omitempty"`
+	// Currency symbol or code that numbers may have before or after them, such as "kr" or "$".
+	CurrencySymbol string `json:"currencySymbol,omitempty"`
+	// Whether numbers may have a trailing percent sign. Values are stored as written, so "12 %"
+	// becomes 12.
+	Percent bool `json:"percent,omitempty"`
+}
+
+// Number formats that we try when deducing INTEGER and FLOAT columns, in order of preference when a
+// column's values match several of them. The first is the format expected by strconv, which takes
+// precedence so that "1.500" is 1.5. After that, we prefer the formats used in Norway and
+// continental Europe, so "1,500" is deduced as 1.5 unless other values in the column say otherwise.
+var numberFormats = []NumberFormat{
+	{},
+	{DecimalSeparator: ",", ThousandsSeparator: " "},
+	{DecimalSeparator: ",", ThousandsSeparator: "."},
+	{DecimalSeparator: ".", ThousandsSeparator: ","},
+	{DecimalSeparator: ".", ThousandsSeparator: " "},
+	{DecimalSeparator: ".", ThousandsSeparator: "'"},
+}
+
+// Currency symbols and codes that we recognize when deducing number formats.
+var currencySymbols = []string{"NOK", "SEK", "DKK", "EUR", "USD", "GBP", "kr", "€", "$", "£"}
+
+// Non-breaking spaces (regular and narrow) that we accept in place of " " as thousands separator.
+var nonBreakingSpaces = strings.NewReplacer("\u00a0", " ", "\u202f", " ")
+
+// Returns whether the format is the one expected by strconv, in which case Column.NumberFormat
+// should be nil.
+func (format NumberFormat) isDefault() bool {
+	return format == NumberFormat{}
+}
+
+func (format NumberFormat) Validate() error {
+	decimalSeparator := format.decimalSeparator()
+	if format.ThousandsSeparator == decimalSeparator {
+		return errors.New("thousands separator cannot be the same as decimal separator")
+	}
+
+	for _, separator := range []string{decimalSeparator, format.ThousandsSeparator} {
+		if strings.ContainsAny(separator, "0123456789+-") {
+			return fmt.Errorf("invalid number separator '%s'", separator)
+		}
+	}
+
+	return nil
+}
+
+func (format NumberFormat) decimalSeparator() string {
+	if format.DecimalSeparator == "" {
+		return "."
+	}
+	return format.DecimalSeparator
+}
+
+// Returns the given field as a number that strconv and decimal.NewFromString can parse.
+func (format NumberFormat) normalize(field string) (string, error) {
+	number := strings.TrimSpace(field)
+
+	if format.Percent {
+		number = strings.TrimSpace(strings.TrimSuffix(number, "%"))
+	}
+
+	sign, number := cutSign(number)
+	if format.CurrencySymbol != "" {
+		number = strings.TrimSpace(strings.TrimSuffix(number, format.CurrencySymbol))
+		number = strings.TrimSpace(strings.TrimPrefix(number, format.CurrencySymbol))
+	}
+	if sign == "" {
+		sign, number = cutSign(number)
+	}
+
+	if format.DecimalSeparator == "" && format.ThousandsSeparator == "" {
+		return sign + number, nil
+	}
+
+	integerPart, fractionalPart, hasFraction := strings.Cut(number, format.decimalSeparator())
+	if hasFraction && !isDigits(fractionalPart) {
+		return "", fmt.Errorf("invalid fractional part in number '%s'", field)
+	}
+
+	if format.ThousandsSeparator != "" {
+		if format.ThousandsSeparator == " " {
+			integerPart = nonBreakingSpaces.Replace(integerPart)
+		}
+
+		groups := strings.Split(integerPart, format.ThousandsSeparator)
+		if len(groups) > 1 {
+			for i, group := range groups {
+				if (i == 0 && len(group) > 3) || (i != 0 && len(group) != 3) {
+					return "", fmt.Errorf("invalid digit grouping in number '%s'", field)
+				}
+			}
+			integerPart = strings.Join(groups, "")
+		}
+	}
+
+	if !isDigits(integerPart) {
+		return "", fmt.Errorf("invalid number '%s'", field)
+	}
+
+	if hasFraction {
+		return sign + integerPart + "." + fractionalPart, nil
+	}
+	return sign + integerPart, nil
+}
+
+func cutSign(number string) (sign string, rest string) {
+	if strings.HasPrefix(number, "-") || strings.HasPrefix(number, "+") {
+		return number[:1], strings.TrimSpace(number[1:])
+	}
+	return "", number
+}
+
+// Returns true if the given string is non-empty and consists only of ASCII digits.
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, char := range value {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the field as a number that strconv can parse, using the column's NumberFormat.
+func (column Column) normalizeNumber(field string) (string, error) {
+	if column.NumberFormat == nil {
+		return field, nil
+	}
+	return column.NumberFormat.normalize(field)
+}
+
+func (column Column) validateNumberFormat() error {
+	if column.NumberFormat == nil {
+		return nil
+	}
+
+	switch column.DataType {
+	case DataTypeInt, DataTypeFloat, DataTypeDecimal:
+		return column.NumberFormat.Validate()
+	default:
+		return fmt.Errorf(
+			"number format can only be set on %v/%v/%v columns",
+			DataTypeInt,
+			DataTypeFloat,
+			DataTypeDecimal,
+		)
+	}
+}
+
+// A number format that all values in a column have matched so far during schema deduction.
+type numberFormatCandidate struct {
+	format NumberFormat
+	// Whether all values in the column are integers in this format.
+	allIntegers bool
+	// The first non-integer value in the column after integer values, if any.
+	integerWidening *TypeWidening
+}
+
+// Returns the formats from numberFormats that the field can be parsed with, along with any currency
+// symbol or percent sign found in the field.
+func matchingNumberFormats(field string) []numberFormatCandidate {
+	currencySymbol, hasPercent := findNumberAffixes(field)
+
+	var candidates []numberFormatCandidate
+	for _, format := range numberFormats {
+		format.CurrencySymbol = currencySymbol
+		format.Percent = hasPercent
+
+		number, err := format.normalize(field)
+		if err != nil {
+			continue
+		}
+		if _, err := strconv.ParseFloat(number, 64); err != nil {
+			continue
+		}
+
+		_, intErr := strconv.ParseInt(number, 10, 64)
+		candidates = append(candidates, numberFormatCandidate{
+			format:      format,
+			allIntegers: intErr == nil,
+		})
+	}
+	return candidates
+}
+
+func findNumberAffixes(field string) (currencySymbol string, hasPercent bool) {
+	field = strings.TrimSpace(field)
+
+	if strings.HasSuffix(field, "%") {
+		hasPercent = true
+		field = strings.TrimSpace(strings.TrimSuffix(field, "%"))
+	}
+
+	_, field = cutSign(field)
+	for _, symbol := range currencySymbols {
+		if strings.HasPrefix(field, symbol) || strings.HasSuffix(field, symbol) {
+			return symbol, hasPercent
+		}
+	}
+
+	return "", hasPercent
+}
+
+// Returns the candidates that are in both the given lists, keeping the order of the first list.
+// Candidates match if they have the same separators and no conflicting currency symbols.
+func intersectNumberFormats(
+	candidates []numberFormatCandidate,
+	otherCandidates []numberFormatCandidate,
+) []numberFormatCandidate {
+	var intersection []numberFormatCandidate
+	for _, candidate := range candidates {
+		for _, other := range otherCandidates {
+			format, otherFormat := candidate.format, other.format
+			if format.DecimalSeparator != otherFormat.DecimalSeparator ||
+				format.ThousandsSeparator != otherFormat.ThousandsSeparator {
+				continue
+			}
+
+			if format.CurrencySymbol == "" {
+				format.CurrencySymbol = otherFormat.CurrencySymbol
+			} else if otherFormat.CurrencySymbol != "" &&
+				otherFormat.CurrencySymbol != format.CurrencySymbol {
+				continue
+			}
+			format.Percent = format.Percent || otherFormat.Percent
+
+			integerWidening := candidate.integerWidening
+			if candidate.allIntegers && !other.allIntegers {
+				integerWidening = other.integerWidening
+			}
+
+			intersection = append(intersection, numberFormatCandidate{
+				format:          format,
+				allIntegers:     candidate.allIntegers && other.allIntegers,
+				integerWidening: integerWidening,
+			})
+			break
+		}
+	}
+	return intersection
+}
@@ -0,0 +1,173 @@
+package db
+
+import "testing"
+
+func TestNormalizeNumber(t *testing.T) {
+	testCases := []struct {
+		name    string
+		format  NumberFormat
+		field   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "Default format",
+			format: NumberFormat{},
+			field:  "-1234.5",
+			want:   "-1234.5",
+		},
+		{
+			name:   "Decimal comma with space grouping",
+			format: NumberFormat{DecimalSeparator: ",", ThousandsSeparator: " "},
+			field:  "1 234 567,89",
+			want:   "1234567.89",
+		},
+		{
+			name:   "Non-breaking space grouping",
+			format: NumberFormat{DecimalSeparator: ",", ThousandsSeparator: " "},
+			field:  "1\u00a0234,5",
+			want:   "1234.5",
+		},
+		{
+			name:   "Decimal point with comma grouping",
+			format: NumberFormat{DecimalSeparator: ".", ThousandsSeparator: ","},
+			field:  "1,234.56",
+			want:   "1234.56",
+		},
+		{
+			name: "Currency symbol and sign",
+			format: NumberFormat{
+				DecimalSeparator:   ",",
+				ThousandsSeparator: ".",
+				CurrencySymbol:     "kr",
+			},
+			field: "-kr 1.234,50",
+			want:  "-1234.50",
+		},
+		{
+			name:   "Percent",
+			format: NumberFormat{DecimalSeparator: ",", Percent: true},
+			field:  "12,5 %",
+			want:   "12.5",
+		},
+		{
+			name:    "Invalid grouping",
+			format:  NumberFormat{DecimalSeparator: ".", ThousandsSeparator: ","},
+			field:   "12,34.5",
+			wantErr: true,
+		},
+		{
+			name:    "Separator in fractional part",
+			format:  NumberFormat{DecimalSeparator: ",", ThousandsSeparator: " "},
+			field:   "1,500.25",
+			wantErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			number, err := testCase.format.normalize(testCase.field)
+			if testCase.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got number %q", number)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if number != testCase.want {
+				t.Errorf("expected number %q, got %q", testCase.want, number)
+			}
+		})
+	}
+}
+
+func TestDeduceNumberColumns(t *testing.T) {
+	testCases := []struct {
+		name             string
+		fields           []string
+		wantDataType     DataType
+		wantNumberFormat *NumberFormat
+	}{
+		{
+			name:         "Plain integers",
+			fields:       []string{"10", "-20", ""},
+			wantDataType: DataTypeInt,
+		},
+		{
+			name:         "Ambiguous decimal point",
+			fields:       []string{"1.500", "2.25"},
+			wantDataType: DataTypeFloat,
+		},
+		{
+			name:             "Ambiguous decimal comma",
+			fields:           []string{"1,500"},
+			wantDataType:     DataTypeFloat,
+			wantNumberFormat: &NumberFormat{DecimalSeparator: ",", ThousandsSeparator: " "},
+		},
+		{
+			name:             "Decimal comma resolved by other values",
+			fields:           []string{"1,500", "2,5"},
+			wantDataType:     DataTypeFloat,
+			wantNumberFormat: &NumberFormat{DecimalSeparator: ",", ThousandsSeparator: " "},
+		},
+		{
+			name:             "Thousands comma resolved by other values",
+			fields:           []string{"1,500", "1,500.25"},
+			wantDataType:     DataTypeFloat,
+			wantNumberFormat: &NumberFormat{DecimalSeparator: ".", ThousandsSeparator: ","},
+		},
+		{
+			name:             "Thousands point resolved by other values",
+			fields:           []string{"1.500", "2.000.000"},
+			wantDataType:     DataTypeInt,
+			wantNumberFormat: &NumberFormat{DecimalSeparator: ",", ThousandsSeparator: "."},
+		},
+		{
+			name:             "Space grouping",
+			fields:           []string{"1 234,56", "12,5"},
+			wantDataType:     DataTypeFloat,
+			wantNumberFormat: &NumberFormat{DecimalSeparator: ",", ThousandsSeparator: " "},
+		},
+		{
+			name:         "Currency",
+			fields:       []string{"kr 1 234,50", "kr 99"},
+			wantDataType: DataTypeFloat,
+			wantNumberFormat: &NumberFormat{
+				DecimalSeparator:   ",",
+				ThousandsSeparator: " ",
+				CurrencySymbol:     "kr",
+			},
+		},
+		{
+			name:             "Percent",
+			fields:           []string{"12 %", "7.5 %"},
+			wantDataType:     DataTypeFloat,
+			wantNumberFormat: &NumberFormat{Percent: true},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			column := deduceTestColumn(t, "value", testCase.fields)
+			if column.DataType != testCase.wantDataType {
+				t.Errorf("expected data type %v, got %v", testCase.wantDataType, column.DataType)
+			}
+
+			if testCase.wantNumberFormat == nil {
+				if column.NumberFormat != nil {
+					t.Errorf("expected no number format, got %+v", *column.NumberFormat)
+				}
+			} else if column.NumberFormat == nil {
+				t.Errorf("expected number format %+v, got none", *testCase.wantNumberFormat)
+			} else if *column.NumberFormat != *testCase.wantNumberFormat {
+				t.Errorf(
+					"expected number format %+v, got %+v",
+					*testCase.wantNumberFormat,
+					*column.NumberFormat,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Options for how raw fields are parsed into column values, used both when deducing data types
+// and when converting fields on ingestion.
+type ParseOptions struct {
+	// Values to parse as true in BOOLEAN columns (case-insensitive). If empty, DefaultTrueValues is
+	// used. When TrueValues or FalseValues are set, fields matching them are deduced as BOOLEAN
+	// even if they are numbers, so that e.g. "1" and "0" can be used.
+	TrueValues []string `json:"trueValues,omitempty"`
+	// Values to parse as false in BOOLEAN columns (case-insensitive). If empty, DefaultFalseValues is
+	// used.
+	FalseValues []string `json:"falseValues,omitempty"`
+	// Values to parse as NULL (case-insensitive), such as "NULL", "N/A", "-" or "#N/A". Blank
+	// fields are always NULL.
+	NullValues []string `json:"nullValues,omitempty"`
+	// Whether to remove leading and trailing whitespace from fields.
+	TrimWhitespace bool `json:"trimWhitespace,omitempty"`
+	// Whether to remove a pair of double or single quotes around fields, for files where quotes
+	// were escaped in a way that the CSV reader does not remove. Applied after TrimWhitespace.
+	StripQuotes bool `json:"stripQuotes,omitempty"`
+}
+
+var (
+	DefaultTrueValues  = []string{"true", "yes"}
+	DefaultFalseValues = []string{"false", "no"}
+)
+
+func (options ParseOptions) Validate() error {
+	trueValues, falseValues := options.booleanValues()
+
+	for _, trueValue := range trueValues {
+		if trueValue == "" {
+			return errors.New("true values cannot contain blank strings")
+		}
+		for _, falseValue := range falseValues {
+			if strings.EqualFold(trueValue, falseValue) {
+				return fmt.Errorf("'%s' cannot be both a true and a false value", trueValue)
+			}
+		}
+	}
+
+	for _, falseValue := range falseValues {
+		if falseValue == "" {
+			return errors.New("false values cannot contain blank strings")
+		}
+	}
+
+	for _, nullValue := range options.NullValues {
+		if nullValue == "" {
+			return errors.New("null values cannot contain blank strings, which are always NULL")
+		}
+		if _, isBool := options.parseBool(nullValue); isBool {
+			return fmt.Errorf(
+				"'%s' cannot be both a null value and a true/false value",
+				nullValue,
+			)
+		}
+	}
+
+	return nil
+}
+
+// Applies TrimWhitespace and StripQuotes to the given field, and returns a blank string if it is
+// one of the NullValues. Fields should go through this before deduction and conversion.
+func (options ParseOptions) normalizeField(field string) string {
+	if options.TrimWhitespace {
+		field = strings.TrimSpace(field)
+	}
+
+	if options.StripQuotes && len(field) >= 2 {
+		first, last := field[0], field[len(field)-1]
+		if first == last && (first == '"' || first == '\'') {
+			field = field[1 : len(field)-1]
+		}
+	}
+
+	for _, nullValue := range options.NullValues {
+		if strings.EqualFold(field, nullValue) {
+			return ""
+		}
+	}
+
+	return field
+}
+
+func (options ParseOptions) parseBool(field string) (value bool, ok bool) {
+	trueValues, falseValues := options.booleanValues()
+
+	for _, trueValue := range trueValues {
+		if strings.EqualFold(field, trueValue) {
+			return true, true
+		}
+	}
+	for _, falseValue := range falseValues {
+		if strings.EqualFold(field, falseValue) {
+			return false, true
+		}
+	}
+
+	return false, false
+}
+
+func (options ParseOptions) hasCustomBooleanValues() bool {
+	return len(options.TrueValues) != 0 || len(options.FalseValues) != 0
+}
+
+func (options ParseOptions) booleanValues() (trueValues []string, falseValues []string) {
+	trueValues = options.TrueValues
+	if len(trueValues) == 0 {
+		trueValues = DefaultTrueValues
+	}
+
+	falseValues = options.FalseValues
+	if len(falseValues) == 0 {
+		falseValues = DefaultFalseValues
+	}
+
+	return trueValues, falseValues
+}
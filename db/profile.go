@@ -0,0 +1,46 @@
+package db
+
+// Number of most common values to include for each column in a table profile.
+const ProfileTopValuesLimit = 5
+
+type TableProfile struct {
+	TableName string          `json:"tableName"`
+	RowCount  int64           `json:"rowCount"`
+	Columns   []ColumnProfile `json:"columns"`
+}
+
+type ColumnProfile struct {
+	Name      string   `json:"name"`
+	DataType  DataType `json:"dataType"`
+	NullCount int64    `json:"nullCount"`
+	// May be approximate for large tables. Always 0 for GEO_POINT columns (see HasDistinctValues).
+	DistinctCount int64 `json:"distinctCount"`
+	// Only present for INTEGER, FLOAT and DATETIME columns with at least one non-NULL value.
+	Min DBValue `json:"min"`
+	Max DBValue `json:"max"`
+	// Only present for INTEGER and FLOAT columns with at least one non-NULL value.
+	Mean *float64 `json:"mean"`
+	// The most common values in the column, sorted by descending count. Not present for GEO_POINT
+	// columns. Values and counts may be approximate for large tables, like DistinctCount.
+	TopValues []DistinctValue `json:"topValues"`
+}
+
+func NewColumnProfile(column Column) ColumnProfile {
+	return ColumnProfile{Name: column.Name, DataType: column.DataType}
+}
+
+// Returns true if min/max values are included in profiles of columns of the given data type.
+func HasMinMaxProfile(dataType DataType) bool {
+	return dataType.IsNumeric() || dataType.IsTemporal()
+}
+
+// Returns true if mean values are included in profiles of columns of the given data type.
+func HasMeanProfile(dataType DataType) bool {
+	return dataType.IsNumeric()
+}
+
+// Returns true if columns of the given data type support distinct value queries and counts. This is
+// not the case for GEO_POINT, as Elasticsearch can only group geo points by grid cells.
+func HasDistinctValues(dataType DataType) bool {
+	return dataType != DataTypeGeoPoint
+}
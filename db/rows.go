@@ -0,0 +1,214 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"hermannm.dev/wrap"
+)
+
+const (
+	MaxRowsLimit           = 1000
+	MaxDistinctValuesLimit = 1000
+)
+
+type RowsQuery struct {
+	// Names of the columns to fetch. If empty, all columns in the table schema are fetched.
+	Columns []string     `json:"columns"`
+	SortBy  []ColumnSort `json:"sortBy"`
+	Filters []Filter     `json:"filters"`
+	Limit   int          `json:"limit"`
+	// Cursor from RowsResult.NextCursor of the previous page. Blank to fetch the first page.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type ColumnSort struct {
+	FieldName string    `json:"fieldName"`
+	SortOrder SortOrder `json:"sortOrder"`
+}
+
+type Filter struct {
+	FieldName string         `json:"fieldName"`
+	Operator  FilterOperator `json:"operator"`
+	// JSON value to compare against, in the format of the column's data type. Omitted for
+	// IS_NULL/IS_NOT_NULL.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type RowsResult struct {
+	Columns []Column `json:"columns"`
+	// Each row has one value per column in Columns, in the same order. NULL values are nil.
+	Rows [][]DBValue `json:"rows"`
+	// Cursor to pass in the next RowsQuery to fetch the next page. Blank if there are no more
+	// rows.
+	NextCursor string `json:"nextCursor"`
+}
+
+type DistinctValuesQuery struct {
+	FieldName string `json:"fieldName"`
+	// If not blank, only values starting with this prefix are returned. May only be given for
+	// TEXT/UUID/CATEGORY/TAGS columns.
+	SearchPrefix string `json:"searchPrefix"`
+	Limit        int    `json:"limit"`
+}
+
+// A distinct value of a column, with the number of rows that have it.
+type DistinctValue struct {
+	Value DBValue `json:"value"`
+	Count int64   `json:"count"`
+}
+
+// Validates the query against the given table schema, and returns the columns to fetch.
+func (query RowsQuery) Validate(schema TableSchema) (columns []Column, err error) {
+	if query.Limit <= 0 || query.Limit > MaxRowsLimit {
+		return nil, fmt.Errorf("row limit must be between 1 and %d", MaxRowsLimit)
+	}
+
+	if len(query.Columns) == 0 {
+		columns = schema.Columns
+	} else {
+		columns = make([]Column, 0, len(query.Columns))
+		for _, columnName := range query.Columns {
+			column, ok := schema.GetColumn(columnName)
+			if !ok {
+				return nil, fmt.Errorf("selected column '%s' not found in table", columnName)
+			}
+			columns = append(columns, column)
+		}
+	}
+
+	for _, sort := range query.SortBy {
+		column, ok := schema.GetColumn(sort.FieldName)
+		if !ok {
+			return nil, fmt.Errorf("sort column '%s' not found in table", sort.FieldName)
+		}
+		// Elasticsearch can only sort geo_point fields by distance to a given point
+		if column.DataType == DataTypeGeoPoint {
+			return nil, fmt.Errorf("cannot sort by %v column '%s'", column.DataType, column.Name)
+		}
+		if !sort.SortOrder.IsValid() {
+			return nil, fmt.Errorf("invalid sort order for column '%s'", sort.FieldName)
+		}
+	}
+
+	for _, filter := range query.Filters {
+		column, ok := schema.GetColumn(filter.FieldName)
+		if !ok {
+			return nil, fmt.Errorf("filter column '%s' not found in table", filter.FieldName)
+		}
+		if _, err := filter.ParseValue(column.DataType); err != nil {
+			return nil, wrap.Errorf(err, "invalid filter on column '%s'", filter.FieldName)
+		}
+	}
+
+	return columns, nil
+}
+
+// Validates the query against the given table schema, and returns the column to get values from.
+func (query DistinctValuesQuery) Validate(schema TableSchema) (Column, error) {
+	if query.Limit <= 0 || query.Limit > MaxDistinctValuesLimit {
+		return Column{}, fmt.Errorf(
+			"distinct values limit must be between 1 and %d",
+			MaxDistinctValuesLimit,
+		)
+	}
+
+	column, ok := schema.GetColumn(query.FieldName)
+	if !ok {
+		return Column{}, fmt.Errorf("column '%s' not found in table", query.FieldName)
+	}
+
+	if !HasDistinctValues(column.DataType) {
+		return Column{}, fmt.Errorf("cannot get distinct values of %v columns", column.DataType)
+	}
+
+	if query.SearchPrefix != "" {
+		if err := column.DataType.IsValidForPrefixSearch(); err != nil {
+			return Column{}, err
+		}
+	}
+
+	return column, nil
+}
+
+// Parses the filter's JSON value into a DBValue of the given column data type's value data type
+// (see DataType.ValueDataType). Returns a nil DBValue for IS_NULL/IS_NOT_NULL filters.
+//
+// On TAGS columns, EQUALS/NOT_EQUALS match rows that have/don't have the given tag, and
+// IS_NULL/IS_NOT_NULL match rows that have no tags/any tags. Other operators are not supported.
+// GEO_POINT columns only support IS_NULL/IS_NOT_NULL.
+func (filter Filter) ParseValue(dataType DataType) (DBValue, error) {
+	if !filter.Operator.IsValid() {
+		return nil, errors.New("invalid filter operator")
+	}
+
+	if (dataType == DataTypeTags && filter.Operator.IsRangeComparison()) ||
+		(dataType == DataTypeGeoPoint && !filter.Operator.IsNullCheck()) {
+		return nil, fmt.Errorf("operator %v is not supported on %v columns", filter.Operator, dataType)
+	}
+
+	if filter.Operator.IsNullCheck() {
+		return nil, nil
+	}
+
+	if len(filter.Value) == 0 || string(filter.Value) == "null" {
+		return nil, fmt.Errorf("missing filter value for operator %v", filter.Operator)
+	}
+
+	valueDataType := dataType.ValueDataType()
+	value, err := NewDBValue(valueDataType)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(filter.Value, value); err != nil {
+		return nil, wrap.Errorf(err, "failed to parse filter value as %v", valueDataType)
+	}
+
+	return value, nil
+}
+
+// Encodes a database-specific pagination cursor into an opaque string for clients.
+func EncodeCursor(cursor any) (string, error) {
+	cursorJSON, err := json.Marshal(cursor)
+	if err != nil {
+		return "", wrap.Error(err, "failed to encode pagination cursor")
+	}
+	return base64.RawURLEncoding.EncodeToString(cursorJSON), nil
+}
+
+// Returned by DecodeCursor when a client sends a cursor that was not created by EncodeCursor, or
+// that has been tampered with.
+type InvalidCursorError struct {
+	Err error
+}
+
+func (err InvalidCursorError) Error() string {
+	return "invalid pagination cursor: " + err.Err.Error()
+}
+
+func (err InvalidCursorError) Unwrap() error {
+	return err.Err
+}
+
+// Database-specific pagination cursor, validated after decoding.
+type Cursor interface {
+	Validate() error
+}
+
+// Decodes a cursor string from EncodeCursor into the given pointer. Returns InvalidCursorError if
+// the cursor is malformed or fails validation.
+func DecodeCursor(encodedCursor string, cursor Cursor) error {
+	cursorJSON, err := base64.RawURLEncoding.DecodeString(encodedCursor)
+	if err != nil {
+		return InvalidCursorError{Err: wrap.Error(err, "malformed base64")}
+	}
+	if err := json.Unmarshal(cursorJSON, cursor); err != nil {
+		return InvalidCursorError{Err: wrap.Error(err, "failed to parse cursor JSON")}
+	}
+	if err := cursor.Validate(); err != nil {
+		return InvalidCursorError{Err: err}
+	}
+	return nil
+}
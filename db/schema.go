@@ -1,103 +1,165 @@
 package db
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"hermannm.dev/wrap"
 )
 
 type TableSchema struct {
-	TableName string   `json:"tableName"`
-	Columns   []Column `json:"columns"`
+	TableName    string       `json:"tableName"`
+	Columns      []Column     `json:"columns"`
+	ParseOptions ParseOptions `json:"parseOptions"`
 }
 
 type Column struct {
 	Name     string   `json:"name"`
 	DataType DataType `json:"dataType"`
 	Optional bool     `json:"optional"`
+	// Name to display for the column. If blank, Name is used. For deduced schemas, this is the
+	// original header of the column, if it was changed when normalizing the column name.
+	Label string `json:"label,omitempty"`
+	// Description of the column's contents, for display.
+	Description string `json:"description,omitempty"`
+	// Unit of the column's values, such as "NOK" or "%", for display.
+	Unit string `json:"unit,omitempty"`
+	// Total number of digits. May only be present if DataType is DECIMAL.
+	Precision int `json:"precision,omitempty"`
+	// Number of digits after the decimal point. May only be present if DataType is DECIMAL.
+	Scale int `json:"scale,omitempty"`
+	// Separator between tags in a field. May only be present if DataType is TAGS. If blank,
+	// DefaultTagSeparator is used.
+	TagSeparator string `json:"tagSeparator,omitempty"`
+	// Name of another column with longitudes, for GEO_POINT columns built from separate latitude
+	// and longitude columns. The GEO_POINT column's own fields are then latitudes. May only be
+	// present if DataType is GEO_POINT. If blank, fields are parsed as "latitude,longitude".
+	LongitudeColumn string `json:"longitudeColumn,omitempty"`
+	// Layout (see time.Layout) for parsing fields in DATETIME/DATE columns, or one of the Unix
+	// timestamp formats DateTimeFormatUnixSeconds/DateTimeFormatUnixMilliseconds for DATETIME
+	// columns. May only be present if DataType is DATETIME or DATE. If blank, DATETIME fields are
+	// parsed as RFC3339, and DATE fields as DateFormat.
+	DateTimeFormat string `json:"dateTimeFormat,omitempty"`
+	// May only be present if DataType is INTEGER, FLOAT or DECIMAL. If nil, fields are parsed with
+	// Go's strconv package.
+	NumberFormat *NumberFormat `json:"numberFormat,omitempty"`
 }
 
-func NewTableSchema(columnNames []string) TableSchema {
-	columns := make([]Column, 0, len(columnNames))
-	for _, columnName := range columnNames {
-		columns = append(columns, Column{Name: columnName})
-	}
+const DefaultTagSeparator = "|"
 
-	return TableSchema{Columns: columns}
-}
+// Creates a schema with columns for the given headers, without data types. Column names are
+// normalized with NormalizeColumnNames, and headers that were changed by this are kept as labels.
+func NewTableSchema(headers []string, parseOptions ParseOptions) TableSchema {
+	columnNames := NormalizeColumnNames(headers)
 
-func (schema TableSchema) DeduceDataTypesFromRow(row []string) error {
-	for i, field := range row {
-		if i >= len(schema.Columns) {
-			return errors.New("row contains more fields than there are columns")
+	columns := make([]Column, 0, len(headers))
+	for i, header := range headers {
+		column := Column{Name: columnNames[i]}
+		if column.Name != header {
+			column.Label = header
 		}
+		columns = append(columns, column)
+	}
 
-		column := schema.Columns[i]
+	return TableSchema{Columns: columns, ParseOptions: parseOptions}
+}
 
-		deducedType, isBlank := deduceDataTypeFromField(field)
-		if isBlank {
-			column.Optional = true
-		} else if !column.DataType.IsValid() {
-			column.DataType = deducedType
-		} else if column.DataType != deducedType {
-			return fmt.Errorf(
-				"found incompatible data types '%s' and '%s' in column '%s'",
-				column.DataType.String(),
-				deducedType.String(),
-				column.Name,
-			)
+func (schema TableSchema) GetColumn(name string) (column Column, ok bool) {
+	for _, column := range schema.Columns {
+		if column.Name == name {
+			return column, true
 		}
-
-		schema.Columns[i] = column
 	}
+	return Column{}, false
+}
 
-	return nil
+// Returns -1 if no column with the given name was found.
+func (schema TableSchema) getColumnIndex(name string) int {
+	for i, column := range schema.Columns {
+		if column.Name == name {
+			return i
+		}
+	}
+	return -1
 }
 
-func deduceDataTypeFromField(field string) (deducedType DataType, isBlank bool) {
+func deduceDataTypeFromField(
+	field string,
+	options ParseOptions,
+) (deducedType DataType, isBlank bool) {
 	if field == "" {
 		return 0, true
 	}
+	// Explicitly configured true/false values may be numbers (such as "1" and "0"), in which case
+	// the user wants them deduced as BOOLEAN rather than INTEGER
+	if options.hasCustomBooleanValues() {
+		if _, ok := options.parseBool(field); ok {
+			return DataTypeBool, false
+		}
+	}
 	if _, err := strconv.ParseInt(field, 10, 64); err == nil {
 		return DataTypeInt, false
 	}
 	if _, err := strconv.ParseFloat(field, 64); err == nil {
 		return DataTypeFloat, false
 	}
-	if _, err := time.Parse(time.RFC3339, field); err == nil {
+	if _, ok := options.parseBool(field); ok {
+		return DataTypeBool, false
+	}
+	if len(matchingLayouts(field, DateTimeLayouts)) != 0 {
 		return DataTypeDateTime, false
 	}
+	if len(matchingLayouts(field, DateLayouts)) != 0 {
+		return DataTypeDate, false
+	}
 	if _, err := uuid.Parse(field); err == nil {
 		return DataTypeUUID, false
 	}
+	if isGeoPointField(field) {
+		return DataTypeGeoPoint, false
+	}
 	return DataTypeText, false
 }
 
-func (schema TableSchema) ConvertRowToMap(rawRow []string) (map[string]any, error) {
+func (schema TableSchema) validateFieldCount(rawRow []string) error {
 	if len(rawRow) != len(schema.Columns) {
-		return nil, errors.New(
-			"given row has more fields than there are columns in the table schema",
+		return fmt.Errorf(
+			"wrong number of fields: expected %d, got %d",
+			len(schema.Columns),
+			len(rawRow),
 		)
 	}
+	return nil
+}
+
+func (schema TableSchema) ConvertRowToMap(rawRow []string) (map[string]any, error) {
+	if err := schema.validateFieldCount(rawRow); err != nil {
+		return nil, err
+	}
 
 	rowMap := make(map[string]any, len(schema.Columns))
 
-	for i, field := range rawRow {
+	for i := range rawRow {
 		column := schema.Columns[i]
 
-		convertedField, err := convertField(field, column)
+		field, err := schema.fieldForColumn(rawRow, i)
 		if err != nil {
-			return nil, wrap.Errorf(
-				err,
-				"failed to convert field '%s' to %s for column '%s'",
-				field,
-				column.DataType,
-				column.Name,
-			)
+			return nil, wrap.Errorf(err, "failed to get field for column '%s'", column.Name)
+		}
+
+		convertedField, err := convertField(field, column, schema.ParseOptions)
+		if err != nil {
+			return nil, FieldConversionError{
+				ColumnName: column.Name,
+				DataType:   column.DataType,
+				Value:      rawRow[i],
+				Err:        err,
+			}
 		}
 
 		rowMap[column.Name] = convertedField
@@ -107,24 +169,26 @@ func (schema TableSchema) ConvertRowToMap(rawRow []string) (map[string]any, erro
 }
 
 func (schema TableSchema) ConvertAndAppendRow(convertedRow []any, rawRow []string) ([]any, error) {
-	if len(rawRow) != len(schema.Columns) {
-		return nil, errors.New(
-			"given row has more fields than there are columns in the table schema",
-		)
+	if err := schema.validateFieldCount(rawRow); err != nil {
+		return nil, err
 	}
 
-	for i, field := range rawRow {
+	for i := range rawRow {
 		column := schema.Columns[i]
 
-		convertedField, err := convertField(field, column)
+		field, err := schema.fieldForColumn(rawRow, i)
 		if err != nil {
-			return nil, wrap.Errorf(
-				err,
-				"failed to convert field '%s' to %s for column '%s'",
-				field,
-				column.DataType,
-				column.Name,
-			)
+			return nil, wrap.Errorf(err, "failed to get field for column '%s'", column.Name)
+		}
+
+		convertedField, err := convertField(field, column, schema.ParseOptions)
+		if err != nil {
+			return nil, FieldConversionError{
+				ColumnName: column.Name,
+				DataType:   column.DataType,
+				Value:      rawRow[i],
+				Err:        err,
+			}
 		}
 
 		convertedRow = append(convertedRow, convertedField)
@@ -133,7 +197,15 @@ func (schema TableSchema) ConvertAndAppendRow(convertedRow []any, rawRow []strin
 	return convertedRow, nil
 }
 
-func convertField(field string, column Column) (convertedField any, err error) {
+func convertField(
+	field string,
+	column Column,
+	options ParseOptions,
+) (convertedField any, err error) {
+	if column.DataType == DataTypeTags {
+		return column.parseTags(field), nil
+	}
+
 	if field == "" {
 		if column.Optional {
 			return nil, nil
@@ -143,17 +215,32 @@ func convertField(field string, column Column) (convertedField any, err error) {
 	}
 
 	switch column.DataType {
-	case DataTypeInt:
-		return strconv.ParseInt(field, 10, 64)
-	case DataTypeFloat:
-		return strconv.ParseFloat(field, 64)
+	case DataTypeInt, DataTypeFloat, DataTypeDecimal:
+		number, err := column.normalizeNumber(field)
+		if err != nil {
+			return nil, err
+		}
+		return convertNumber(number, column)
+	case DataTypeBool:
+		value, ok := options.parseBool(field)
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not a recognized true/false value", field)
+		}
+		return value, nil
 	case DataTypeDateTime:
-		value, err := time.Parse(time.RFC3339, field)
+		value, err := column.parseDateTime(field)
 		if err == nil {
 			return value.UnixMilli(), nil
 		} else {
 			return nil, err
 		}
+	case DataTypeDate:
+		value, err := column.parseDateTime(field)
+		if err != nil {
+			return nil, err
+		}
+		// Both ClickHouse's Date32 and our Elasticsearch date mapping accept dates in this format
+		return value.Format(DateFormat), nil
 	case DataTypeUUID:
 		if _, err := uuid.Parse(field); err != nil {
 			return nil, wrap.Errorf(
@@ -164,13 +251,34 @@ func convertField(field string, column Column) (convertedField any, err error) {
 			)
 		}
 		return field, nil
-	case DataTypeText:
+	case DataTypeGeoPoint:
+		return parseGeoPoint(field)
+	case DataTypeText, DataTypeCategory:
 		return field, nil
 	}
 
 	return nil, fmt.Errorf("unrecognized data type '%s' in column", column.DataType)
 }
 
+// Converts a number that has been normalized with the column's NumberFormat.
+func convertNumber(number string, column Column) (convertedNumber any, err error) {
+	switch column.DataType {
+	case DataTypeInt:
+		return strconv.ParseInt(number, 10, 64)
+	case DataTypeFloat:
+		return strconv.ParseFloat(number, 64)
+	default:
+		value, err := decimal.NewFromString(number)
+		if err != nil {
+			return nil, err
+		}
+		if err := column.checkDecimalBounds(value); err != nil {
+			return nil, wrap.Errorf(err, "invalid value '%s'", number)
+		}
+		return value, nil
+	}
+}
+
 func (schema TableSchema) Validate() error {
 	if schema.TableName == "" {
 		return errors.New("table name cannot be blank")
@@ -180,6 +288,10 @@ func (schema TableSchema) Validate() error {
 		return wrap.Errors("invalid schema columns", errs...)
 	}
 
+	if err := schema.ParseOptions.Validate(); err != nil {
+		return wrap.Error(err, "invalid parse options")
+	}
+
 	return nil
 }
 
@@ -190,6 +302,9 @@ func (schema TableSchema) ValidateColumns() []error {
 		if err := column.Validate(); err != nil {
 			errs = append(errs, fmt.Errorf("column %d ('%s'): %w", i, column.Name, err))
 		}
+		if err := schema.validateLongitudeColumn(column); err != nil {
+			errs = append(errs, fmt.Errorf("column %d ('%s'): %w", i, column.Name, err))
+		}
 	}
 
 	return errs
@@ -204,15 +319,88 @@ func (column Column) Validate() error {
 		return errors.New("invalid column data type")
 	}
 
+	if column.DataType == DataTypeDecimal {
+		if column.Precision < 1 || column.Precision > MaxDecimalPrecision {
+			return fmt.Errorf(
+				"%v column must have precision between 1 and %d",
+				DataTypeDecimal,
+				MaxDecimalPrecision,
+			)
+		}
+		if column.Scale < 0 || column.Scale > column.Precision {
+			return fmt.Errorf("%v column must have scale between 0 and precision", DataTypeDecimal)
+		}
+	} else if column.Precision != 0 || column.Scale != 0 {
+		return fmt.Errorf("precision and scale can only be set on %v columns", DataTypeDecimal)
+	}
+
+	if column.TagSeparator != "" && column.DataType != DataTypeTags {
+		return fmt.Errorf("tag separator can only be set on %v columns", DataTypeTags)
+	}
+
+	if err := column.validateDateTimeFormat(); err != nil {
+		return err
+	}
+
+	if err := column.validateNumberFormat(); err != nil {
+		return wrap.Error(err, "invalid number format")
+	}
+
+	return nil
+}
+
+// Splits the given field into tags, ignoring blank tags and surrounding whitespace. A blank field
+// gives an empty list, which we store instead of NULL, so TAGS columns are never NULL.
+func (column Column) parseTags(field string) []string {
+	separator := column.TagSeparator
+	if separator == "" {
+		separator = DefaultTagSeparator
+	}
+
+	tags := make([]string, 0, strings.Count(field, separator)+1)
+	for _, tag := range strings.Split(field, separator) {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Checks that the given value fits within the column's DECIMAL precision and scale, as databases
+// would otherwise round or reject it.
+func (column Column) checkDecimalBounds(value decimal.Decimal) error {
+	if decimalPlaces := -value.Exponent(); decimalPlaces > int32(column.Scale) {
+		return fmt.Errorf("value has more than %d decimal places", column.Scale)
+	}
+
+	// The integer part must fit in the digits not reserved for decimal places
+	if value.Abs().Cmp(decimal.New(1, int32(column.Precision-column.Scale))) >= 0 {
+		return fmt.Errorf(
+			"value has more than %d digits before the decimal point",
+			column.Precision-column.Scale,
+		)
+	}
+
 	return nil
 }
 
 const (
-	StoredSchemasTable          = "analysis_schemas"
-	StoredSchemaName            = "table_name"
-	StoredSchemaColumnNames     = "column_names"
-	StoredSchemaColumnDataTypes = "column_data_types"
-	StoredSchemaColumnOptionals = "column_optionals"
+	StoredSchemasTable                 = "analysis_schemas"
+	StoredSchemaName                   = "table_name"
+	StoredSchemaColumnNames            = "column_names"
+	StoredSchemaColumnDataTypes        = "column_data_types"
+	StoredSchemaColumnOptionals        = "column_optionals"
+	StoredSchemaParseOptions           = "parse_options"
+	StoredSchemaColumnPrecisions       = "column_precisions"
+	StoredSchemaColumnScales           = "column_scales"
+	StoredSchemaColumnTagSeparators    = "column_tag_separators"
+	StoredSchemaColumnLongitudeColumns = "column_longitude_columns"
+	StoredSchemaColumnDateTimeFormats  = "column_datetime_formats"
+	StoredSchemaColumnNumberFormats    = "column_number_formats"
+	StoredSchemaColumnLabels           = "column_labels"
+	StoredSchemaColumnDescriptions     = "column_descriptions"
+	StoredSchemaColumnUnits            = "column_units"
 )
 
 type StoredTableSchema struct {
@@ -220,11 +408,39 @@ type StoredTableSchema struct {
 	ColumnNames []string `json:"column_names"`
 	DataTypes   []int8   `json:"column_data_types"`
 	Optionals   []bool   `json:"column_optionals"`
+	// JSON-encoded ParseOptions. May be blank for schemas stored before parse options were added.
+	ParseOptions string `json:"parse_options"`
+	// May be empty for schemas stored before DECIMAL columns were added.
+	Precisions []int8 `json:"column_precisions"`
+	Scales     []int8 `json:"column_scales"`
+	// May be empty for schemas stored before TAGS columns were added.
+	TagSeparators []string `json:"column_tag_separators"`
+	// May be empty for schemas stored before GEO_POINT columns were added.
+	LongitudeColumns []string `json:"column_longitude_columns"`
+	// May be empty for schemas stored before date/time formats were added.
+	DateTimeFormats []string `json:"column_datetime_formats"`
+	// JSON-encoded NumberFormats, or blank for columns without one. May be empty for schemas stored
+	// before number formats were added.
+	NumberFormats []string `json:"column_number_formats"`
+	// May be empty for schemas stored before column labels were added.
+	Labels []string `json:"column_labels"`
+	// May be empty for schemas stored before column descriptions and units were added.
+	Descriptions []string `json:"column_descriptions"`
+	Units        []string `json:"column_units"`
 }
 
 func (storedSchema StoredTableSchema) ToSchema() (TableSchema, error) {
 	columnCount := len(storedSchema.ColumnNames)
-	if len(storedSchema.DataTypes) != columnCount || len(storedSchema.Optionals) != columnCount {
+	if len(storedSchema.DataTypes) != columnCount || len(storedSchema.Optionals) != columnCount ||
+		!isValidOptionalStoredColumnCount(len(storedSchema.Precisions), columnCount) ||
+		!isValidOptionalStoredColumnCount(len(storedSchema.Scales), columnCount) ||
+		!isValidOptionalStoredColumnCount(len(storedSchema.TagSeparators), columnCount) ||
+		!isValidOptionalStoredColumnCount(len(storedSchema.LongitudeColumns), columnCount) ||
+		!isValidOptionalStoredColumnCount(len(storedSchema.DateTimeFormats), columnCount) ||
+		!isValidOptionalStoredColumnCount(len(storedSchema.NumberFormats), columnCount) ||
+		!isValidOptionalStoredColumnCount(len(storedSchema.Labels), columnCount) ||
+		!isValidOptionalStoredColumnCount(len(storedSchema.Descriptions), columnCount) ||
+		!isValidOptionalStoredColumnCount(len(storedSchema.Units), columnCount) {
 		return TableSchema{}, errors.New("stored table schema had inconsistent column counts")
 	}
 
@@ -232,12 +448,56 @@ func (storedSchema StoredTableSchema) ToSchema() (TableSchema, error) {
 		TableName: storedSchema.TableName,
 		Columns:   make([]Column, columnCount),
 	}
+	if storedSchema.ParseOptions != "" {
+		if err := json.Unmarshal(
+			[]byte(storedSchema.ParseOptions),
+			&schema.ParseOptions,
+		); err != nil {
+			return TableSchema{}, wrap.Error(err, "failed to parse stored parse options")
+		}
+	}
 	for i := 0; i < columnCount; i++ {
 		schema.Columns[i] = Column{
 			Name:     storedSchema.ColumnNames[i],
 			DataType: DataType(storedSchema.DataTypes[i]),
 			Optional: storedSchema.Optionals[i],
 		}
+		if len(storedSchema.Precisions) != 0 {
+			schema.Columns[i].Precision = int(storedSchema.Precisions[i])
+		}
+		if len(storedSchema.Scales) != 0 {
+			schema.Columns[i].Scale = int(storedSchema.Scales[i])
+		}
+		if len(storedSchema.TagSeparators) != 0 {
+			schema.Columns[i].TagSeparator = storedSchema.TagSeparators[i]
+		}
+		if len(storedSchema.LongitudeColumns) != 0 {
+			schema.Columns[i].LongitudeColumn = storedSchema.LongitudeColumns[i]
+		}
+		if len(storedSchema.DateTimeFormats) != 0 {
+			schema.Columns[i].DateTimeFormat = storedSchema.DateTimeFormats[i]
+		}
+		if len(storedSchema.NumberFormats) != 0 && storedSchema.NumberFormats[i] != "" {
+			var numberFormat NumberFormat
+			err := json.Unmarshal([]byte(storedSchema.NumberFormats[i]), &numberFormat)
+			if err != nil {
+				return TableSchema{}, wrap.Errorf(
+					err,
+					"failed to parse stored number format for column '%s'",
+					storedSchema.ColumnNames[i],
+				)
+			}
+			schema.Columns[i].NumberFormat = &numberFormat
+		}
+		if len(storedSchema.Labels) != 0 {
+			schema.Columns[i].Label = storedSchema.Labels[i]
+		}
+		if len(storedSchema.Descriptions) != 0 {
+			schema.Columns[i].Description = storedSchema.Descriptions[i]
+		}
+		if len(storedSchema.Units) != 0 {
+			schema.Columns[i].Unit = storedSchema.Units[i]
+		}
 	}
 	if err := schema.Validate(); err != nil {
 		return TableSchema{}, wrap.Error(err, "stored table schema was invalid")
@@ -250,17 +510,50 @@ func (schema TableSchema) ToStored() StoredTableSchema {
 	columnCount := len(schema.Columns)
 
 	storedSchema := StoredTableSchema{
-		TableName:   schema.TableName,
-		ColumnNames: make([]string, columnCount),
-		DataTypes:   make([]int8, columnCount),
-		Optionals:   make([]bool, columnCount),
+		TableName:        schema.TableName,
+		ColumnNames:      make([]string, columnCount),
+		DataTypes:        make([]int8, columnCount),
+		Optionals:        make([]bool, columnCount),
+		Precisions:       make([]int8, columnCount),
+		Scales:           make([]int8, columnCount),
+		TagSeparators:    make([]string, columnCount),
+		LongitudeColumns: make([]string, columnCount),
+		DateTimeFormats:  make([]string, columnCount),
+		NumberFormats:    make([]string, columnCount),
+		Labels:           make([]string, columnCount),
+		Descriptions:     make([]string, columnCount),
+		Units:            make([]string, columnCount),
 	}
 
 	for i, column := range schema.Columns {
 		storedSchema.ColumnNames[i] = column.Name
 		storedSchema.DataTypes[i] = int8(column.DataType)
 		storedSchema.Optionals[i] = column.Optional
+		// Precision and scale are at most MaxDecimalPrecision, so they fit in an int8
+		storedSchema.Precisions[i] = int8(column.Precision)
+		storedSchema.Scales[i] = int8(column.Scale)
+		storedSchema.TagSeparators[i] = column.TagSeparator
+		storedSchema.LongitudeColumns[i] = column.LongitudeColumn
+		storedSchema.DateTimeFormats[i] = column.DateTimeFormat
+		if column.NumberFormat != nil {
+			// Ignores error, as NumberFormat only contains types that can always be encoded
+			numberFormat, _ := json.Marshal(column.NumberFormat)
+			storedSchema.NumberFormats[i] = string(numberFormat)
+		}
+		storedSchema.Labels[i] = column.Label
+		storedSchema.Descriptions[i] = column.Description
+		storedSchema.Units[i] = column.Unit
 	}
 
+	// Ignores error, as ParseOptions only contains types that can always be encoded
+	parseOptions, _ := json.Marshal(schema.ParseOptions)
+	storedSchema.ParseOptions = string(parseOptions)
+
 	return storedSchema
 }
+
+// Per-column fields added to the stored schema after its initial version may be empty for schemas
+// stored before then, in which case the columns get their default values.
+func isValidOptionalStoredColumnCount(count int, columnCount int) bool {
+	return count == 0 || count == columnCount
+}
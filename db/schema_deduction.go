@@ -0,0 +1,328 @@
+package db
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Deduces a table schema from rows of raw fields. In addition to deducing data types field by field
+// (see deduceDataTypeFromField), it tracks state for each column across rows, to detect TEXT
+// columns that should be CATEGORY, the date/time format of DATETIME/DATE columns, the number format
+// of INTEGER/FLOAT columns, and INTEGER columns that are Unix timestamps.
+type SchemaDeducer struct {
+	schema   TableSchema
+	columns  []columnDeduction
+	rowCount int
+}
+
+type columnDeduction struct {
+	// Distinct non-blank values seen for the column. Set to nil once it exceeds
+	// MaxCategoryDistinctValues, as the column can then no longer be a category.
+	distinctValues map[string]struct{}
+	// Number of non-blank values seen for the column.
+	valueCount int
+	// Number of blank values seen for the column.
+	blankCount int
+	// Layouts from DateTimeLayouts/DateLayouts that all values in a DATETIME/DATE column have
+	// matched so far.
+	dateTimeLayouts []string
+	// Number formats that all values in a numeric column have matched so far.
+	numberFormats []numberFormatCandidate
+	// Range of values in an INTEGER column, for detecting Unix timestamps.
+	minInt int64
+	maxInt int64
+	// Widenings of the column's type caused by values that did not fit it, in the order they
+	// happened.
+	widenings []TypeWidening
+	// Number of values that matched each data type, for SchemaDeductionReport.
+	typeMatchCounts map[DataType]int
+	// Examples of values in the column, for SchemaDeductionReport.
+	exampleValues []string
+}
+
+// A change of a column's data type during schema deduction, because a value did not fit the type
+// deduced from earlier rows.
+type TypeWidening struct {
+	ColumnName string   `json:"columnName"`
+	From       DataType `json:"from"`
+	To         DataType `json:"to"`
+	// The row with the first value that did not fit the previous type.
+	RowNumber int    `json:"rowNumber"`
+	Value     string `json:"value"`
+}
+
+const (
+	// Columns with more distinct values than this are never deduced as CATEGORY. This also bounds
+	// the memory used to track distinct values.
+	MaxCategoryDistinctValues = 1000
+	// The maximum ratio of distinct values to total non-blank values for a TEXT column to be
+	// deduced as CATEGORY.
+	MaxCategoryDistinctRatio = 0.2
+	// The minimum number of non-blank values in a TEXT column for it to be deduced as CATEGORY.
+	// With fewer values than this, the distinct value ratio says little about the column.
+	MinCategoryValueCount = 20
+)
+
+func NewSchemaDeducer(headers []string, parseOptions ParseOptions) *SchemaDeducer {
+	deducer := SchemaDeducer{
+		schema:  NewTableSchema(headers, parseOptions),
+		columns: make([]columnDeduction, len(headers)),
+	}
+	for i := range deducer.columns {
+		deducer.columns[i].distinctValues = make(map[string]struct{})
+	}
+	return &deducer
+}
+
+// Deduces data types from the given row, widening column types that don't fit the row's values
+// (see widenDataType). The row number is used to report which rows forced type widenings.
+func (deducer *SchemaDeducer) DeduceDataTypesFromRow(row []string, rowNumber int) error {
+	deducer.rowCount++
+
+	for i, field := range row {
+		if i >= len(deducer.schema.Columns) {
+			return errors.New("row contains more fields than there are columns")
+		}
+
+		column := &deducer.schema.Columns[i]
+		columnDeduction := &deducer.columns[i]
+		field := deducer.schema.ParseOptions.normalizeField(field)
+
+		deducedType, isBlank := deduceDataTypeFromField(field, deducer.schema.ParseOptions)
+		if isBlank {
+			column.Optional = true
+			columnDeduction.blankCount++
+			continue
+		}
+
+		columnDeduction.addToReport(field, deducer.schema.ParseOptions)
+
+		if isNumberField(field, deducedType) {
+			// We don't know whether numbers are integers until we know the column's number format,
+			// so we deduce them all as FLOAT here, and narrow them to INTEGER in Schema
+			deducedType = DataTypeFloat
+		}
+
+		if !column.DataType.IsValid() {
+			column.DataType = deducedType
+		} else if column.DataType != deducedType {
+			widenedType := widenDataType(column.DataType, deducedType)
+			columnDeduction.widen(column, widenedType, rowNumber, field)
+		}
+
+		if ok := columnDeduction.addValue(field, *column, rowNumber); !ok {
+			// Values of the column's type that don't share a common format can only be TEXT. The
+			// value has already been counted for TEXT by addValue, so we don't add it again.
+			columnDeduction.widen(column, DataTypeText, rowNumber, field)
+		}
+	}
+
+	return nil
+}
+
+// Returns the narrowest data type that can hold values of both the given types. INTEGER widens to
+// FLOAT, and all other combinations widen to TEXT.
+func widenDataType(dataType1 DataType, dataType2 DataType) DataType {
+	switch {
+	case dataType1 == dataType2:
+		return dataType1
+	case (dataType1 == DataTypeInt || dataType1 == DataTypeFloat) &&
+		(dataType2 == DataTypeInt || dataType2 == DataTypeFloat):
+		return DataTypeFloat
+	default:
+		return DataTypeText
+	}
+}
+
+func (columnDeduction *columnDeduction) widen(
+	column *Column,
+	widenedType DataType,
+	rowNumber int,
+	field string,
+) {
+	if widenedType == column.DataType {
+		return
+	}
+
+	previousType := column.DataType
+	if previousType == DataTypeFloat && columnDeduction.numberFormats[0].allIntegers {
+		// Numbers are deduced as FLOAT until Schema, so we report the type they would have had
+		previousType = DataTypeInt
+	}
+
+	columnDeduction.widenings = append(columnDeduction.widenings, TypeWidening{
+		ColumnName: column.Name,
+		From:       previousType,
+		To:         widenedType,
+		RowNumber:  rowNumber,
+		Value:      field,
+	})
+	column.DataType = widenedType
+}
+
+// Tracks the given non-blank field for the column. Returns false if the field does not share a
+// common date/time or number format with the column's previous values.
+func (columnDeduction *columnDeduction) addValue(field string, column Column, rowNumber int) bool {
+	columnDeduction.valueCount++
+
+	if columnDeduction.distinctValues != nil {
+		columnDeduction.distinctValues[field] = struct{}{}
+		if len(columnDeduction.distinctValues) > MaxCategoryDistinctValues {
+			columnDeduction.distinctValues = nil
+		}
+	}
+
+	switch column.DataType {
+	case DataTypeDateTime, DataTypeDate:
+		layouts := DateTimeLayouts
+		if column.DataType == DataTypeDate {
+			layouts = DateLayouts
+		}
+
+		fieldLayouts := matchingLayouts(field, layouts)
+		if columnDeduction.valueCount != 1 {
+			fieldLayouts = intersectLayouts(columnDeduction.dateTimeLayouts, fieldLayouts)
+		}
+		if len(fieldLayouts) == 0 {
+			return false
+		}
+
+		columnDeduction.dateTimeLayouts = fieldLayouts
+	case DataTypeFloat:
+		fieldFormats := matchingNumberFormats(field)
+		if columnDeduction.valueCount != 1 {
+			// Records the field on non-integer candidates, in case it ends up widening the column
+			// from INTEGER to FLOAT
+			for i, fieldFormat := range fieldFormats {
+				if !fieldFormat.allIntegers {
+					fieldFormats[i].integerWidening = &TypeWidening{
+						ColumnName: column.Name,
+						From:       DataTypeInt,
+						To:         DataTypeFloat,
+						RowNumber:  rowNumber,
+						Value:      field,
+					}
+				}
+			}
+
+			fieldFormats = intersectNumberFormats(columnDeduction.numberFormats, fieldFormats)
+		}
+		if len(fieldFormats) == 0 {
+			// Leaves the previous formats in place, for widen to report the previous type
+			return false
+		}
+
+		columnDeduction.numberFormats = fieldFormats
+
+		// Only used if the column ends up as an INTEGER column with the default number format, in
+		// which case all values parse here
+		if value, err := strconv.ParseInt(field, 10, 64); err == nil {
+			if columnDeduction.valueCount == 1 || value < columnDeduction.minInt {
+				columnDeduction.minInt = value
+			}
+			if columnDeduction.valueCount == 1 || value > columnDeduction.maxInt {
+				columnDeduction.maxInt = value
+			}
+		}
+	}
+
+	return true
+}
+
+// Returns the deduced schema, along with a report on how it was deduced. Should be called after all
+// rows have been passed to DeduceDataTypesFromRow.
+func (deducer *SchemaDeducer) Schema() (TableSchema, SchemaDeductionReport) {
+	var widenings []TypeWidening
+	columnReports := make([]ColumnDeductionReport, 0, len(deducer.schema.Columns))
+
+	for i, column := range deducer.schema.Columns {
+		columnDeduction := deducer.columns[i]
+
+		// ClickHouse cannot store NULL points, since tuples cannot be Nullable, so we fall back to
+		// TEXT for GEO_POINT columns with blank fields
+		if column.DataType == DataTypeGeoPoint && column.Optional {
+			column.DataType = DataTypeText
+		}
+
+		if column.DataType == DataTypeFloat {
+			numberFormat := columnDeduction.numberFormats[0]
+			if numberFormat.allIntegers {
+				column.DataType = DataTypeInt
+			} else if numberFormat.integerWidening != nil {
+				widenings = append(widenings, *numberFormat.integerWidening)
+			}
+			if !numberFormat.format.isDefault() {
+				column.NumberFormat = &numberFormat.format
+			}
+			if numberFormat.format.CurrencySymbol != "" {
+				column.Unit = numberFormat.format.CurrencySymbol
+			} else if numberFormat.format.Percent {
+				column.Unit = "%"
+			}
+		}
+
+		switch column.DataType {
+		case DataTypeText:
+			if columnDeduction.isCategory() {
+				column.DataType = DataTypeCategory
+			}
+		case DataTypeDateTime, DataTypeDate:
+			// Leaves the format blank if it's the default for the data type
+			layout := columnDeduction.dateTimeLayouts[0]
+			if layout != time.RFC3339 && layout != DateFormat {
+				column.DateTimeFormat = layout
+			}
+		case DataTypeInt:
+			if column.NumberFormat != nil {
+				break
+			}
+
+			unixFormat := deduceUnixTimestampFormat(
+				column.Name,
+				columnDeduction.minInt,
+				columnDeduction.maxInt,
+			)
+			if unixFormat != "" {
+				column.DataType = DataTypeDateTime
+				column.DateTimeFormat = unixFormat
+			}
+		}
+
+		for _, widening := range columnDeduction.widenings {
+			// TEXT columns may have been narrowed to CATEGORY above
+			if widening.To == DataTypeText {
+				widening.To = column.DataType
+			}
+			widenings = append(widenings, widening)
+		}
+
+		deducer.schema.Columns[i] = column
+		columnReports = append(columnReports, columnDeduction.report(column, deducer.rowCount))
+	}
+
+	return deducer.schema, SchemaDeductionReport{Columns: columnReports, TypeWidenings: widenings}
+}
+
+// Returns true if the field is a number, either in the format expected by strconv or in one of the
+// formats we deduce (see numberFormats).
+func isNumberField(field string, deducedType DataType) bool {
+	switch deducedType {
+	case DataTypeInt, DataTypeFloat:
+		return true
+	case DataTypeText:
+		return len(matchingNumberFormats(field)) != 0
+	default:
+		return false
+	}
+}
+
+func (columnDeduction columnDeduction) isCategory() bool {
+	if columnDeduction.distinctValues == nil ||
+		columnDeduction.valueCount < MinCategoryValueCount {
+		return false
+	}
+
+	distinctCount := float64(len(columnDeduction.distinctValues))
+	return distinctCount/float64(columnDeduction.valueCount) <= MaxCategoryDistinctRatio
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// Details on how a schema was deduced, to explain the deduced types and help users choose between
+// alternatives for ambiguous columns.
+type SchemaDeductionReport struct {
+	Columns []ColumnDeductionReport `json:"columns"`
+	// Columns whose types were widened during deduction, because some rows did not fit the type
+	// deduced from earlier rows.
+	TypeWidenings []TypeWidening `json:"typeWidenings,omitempty"`
+}
+
+type ColumnDeductionReport struct {
+	ColumnName string   `json:"columnName"`
+	DataType   DataType `json:"dataType"`
+	// Data types that the column's non-blank values could be parsed as, sorted by the share of
+	// values matching each. TEXT always matches all values.
+	Candidates []DataTypeCandidate `json:"candidates"`
+	// Up to MaxExampleValues distinct non-blank values from the column, in the order they were
+	// seen.
+	ExampleValues []string `json:"exampleValues"`
+	// Share of checked rows where the column was blank, between 0 and 1.
+	NullRatio float64 `json:"nullRatio"`
+	// Number of distinct non-blank values in the checked rows. We stop counting after
+	// MaxCategoryDistinctValues, so higher counts are reported as MaxCategoryDistinctValues+1.
+	DistinctCount int `json:"distinctCount"`
+}
+
+type DataTypeCandidate struct {
+	DataType DataType `json:"dataType"`
+	// Share of the column's non-blank values that could be parsed as the data type, between 0 and
+	// 1.
+	Share float64 `json:"share"`
+}
+
+const MaxExampleValues = 5
+
+// Returns the data types that the given non-blank field could be parsed as. Unlike
+// deduceDataTypeFromField, which picks the narrowest type, this includes all matching types.
+func matchingDataTypes(field string, options ParseOptions) []DataType {
+	var dataTypes []DataType
+
+	if numberFormats := matchingNumberFormats(field); len(numberFormats) != 0 {
+		for _, numberFormat := range numberFormats {
+			if numberFormat.allIntegers {
+				dataTypes = append(dataTypes, DataTypeInt)
+				break
+			}
+		}
+		dataTypes = append(dataTypes, DataTypeFloat)
+	}
+	if _, ok := options.parseBool(field); ok {
+		dataTypes = append(dataTypes, DataTypeBool)
+	}
+	if len(matchingLayouts(field, DateTimeLayouts)) != 0 {
+		dataTypes = append(dataTypes, DataTypeDateTime)
+	}
+	if len(matchingLayouts(field, DateLayouts)) != 0 {
+		dataTypes = append(dataTypes, DataTypeDate)
+	}
+	if _, err := uuid.Parse(field); err == nil {
+		dataTypes = append(dataTypes, DataTypeUUID)
+	}
+	if isGeoPointField(field) {
+		dataTypes = append(dataTypes, DataTypeGeoPoint)
+	}
+
+	return append(dataTypes, DataTypeText)
+}
+
+func (columnDeduction *columnDeduction) addToReport(field string, options ParseOptions) {
+	if columnDeduction.typeMatchCounts == nil {
+		columnDeduction.typeMatchCounts = make(map[DataType]int)
+	}
+	for _, dataType := range matchingDataTypes(field, options) {
+		columnDeduction.typeMatchCounts[dataType]++
+	}
+
+	if len(columnDeduction.exampleValues) < MaxExampleValues &&
+		!slices.Contains(columnDeduction.exampleValues, field) {
+		columnDeduction.exampleValues = append(columnDeduction.exampleValues, field)
+	}
+}
+
+func (columnDeduction columnDeduction) report(column Column, rowCount int) ColumnDeductionReport {
+	report := ColumnDeductionReport{
+		ColumnName:    column.Name,
+		DataType:      column.DataType,
+		Candidates:    make([]DataTypeCandidate, 0, len(columnDeduction.typeMatchCounts)),
+		ExampleValues: columnDeduction.exampleValues,
+	}
+
+	if rowCount != 0 {
+		report.NullRatio = float64(columnDeduction.blankCount) / float64(rowCount)
+	}
+
+	if columnDeduction.distinctValues == nil {
+		report.DistinctCount = MaxCategoryDistinctValues + 1
+	} else {
+		report.DistinctCount = len(columnDeduction.distinctValues)
+	}
+
+	for dataType, matchCount := range columnDeduction.typeMatchCounts {
+		report.Candidates = append(report.Candidates, DataTypeCandidate{
+			DataType: dataType,
+			Share:    float64(matchCount) / float64(columnDeduction.valueCount),
+		})
+	}
+	// On equal shares, TEXT goes last, since every value matches it. Other types are sorted by data
+	// type, to keep the order stable.
+	slices.SortFunc(report.Candidates, func(
+		candidate1 DataTypeCandidate,
+		candidate2 DataTypeCandidate,
+	) int {
+		if candidate1.Share != candidate2.Share {
+			return cmp.Compare(candidate2.Share, candidate1.Share)
+		}
+		if candidate1.DataType == DataTypeText {
+			return 1
+		}
+		if candidate2.DataType == DataTypeText {
+			return -1
+		}
+		return cmp.Compare(candidate1.DataType, candidate2.DataType)
+	})
+
+	if report.ExampleValues == nil {
+		report.ExampleValues = []string{}
+	}
+
+	return report
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestColumnDeductionReport(t *testing.T) {
+	testCases := []struct {
+		name   string
+		fields []string
+		want   ColumnDeductionReport
+	}{
+		{
+			name:   "Integers with blanks",
+			fields: []string{"1", "2", "2", ""},
+			want: ColumnDeductionReport{
+				ColumnName: "value",
+				DataType:   DataTypeInt,
+				Candidates: []DataTypeCandidate{
+					{DataType: DataTypeInt, Share: 1},
+					{DataType: DataTypeFloat, Share: 1},
+					{DataType: DataTypeText, Share: 1},
+				},
+				ExampleValues: []string{"1", "2"},
+				NullRatio:     0.25,
+				DistinctCount: 2,
+			},
+		},
+		{
+			name:   "Ambiguous decimal separator",
+			fields: []string{"1,500", "2,250"},
+			want: ColumnDeductionReport{
+				ColumnName: "value",
+				DataType:   DataTypeFloat,
+				Candidates: []DataTypeCandidate{
+					{DataType: DataTypeInt, Share: 1},
+					{DataType: DataTypeFloat, Share: 1},
+					{DataType: DataTypeText, Share: 1},
+				},
+				ExampleValues: []string{"1,500", "2,250"},
+				DistinctCount: 2,
+			},
+		},
+		{
+			name:   "Booleans widened to text",
+			fields: []string{"yes", "no", "1"},
+			want: ColumnDeductionReport{
+				ColumnName: "value",
+				DataType:   DataTypeText,
+				Candidates: []DataTypeCandidate{
+					{DataType: DataTypeText, Share: 1},
+					{DataType: DataTypeBool, Share: 2.0 / 3},
+					{DataType: DataTypeInt, Share: 1.0 / 3},
+					{DataType: DataTypeFloat, Share: 1.0 / 3},
+				},
+				ExampleValues: []string{"yes", "no", "1"},
+				DistinctCount: 3,
+			},
+		},
+		{
+			name:   "More distinct values than examples",
+			fields: []string{"a", "b", "a", "c", "d", "e", "f", "g"},
+			want: ColumnDeductionReport{
+				ColumnName:    "value",
+				DataType:      DataTypeText,
+				Candidates:    []DataTypeCandidate{{DataType: DataTypeText, Share: 1}},
+				ExampleValues: []string{"a", "b", "c", "d", "e"},
+				DistinctCount: 7,
+			},
+		},
+		{
+			name:   "Only blanks",
+			fields: []string{"", ""},
+			want: ColumnDeductionReport{
+				ColumnName:    "value",
+				Candidates:    []DataTypeCandidate{},
+				ExampleValues: []string{},
+				NullRatio:     1,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			deducer := NewSchemaDeducer([]string{"value"}, ParseOptions{})
+			for i, field := range testCase.fields {
+				if err := deducer.DeduceDataTypesFromRow([]string{field}, i+1); err != nil {
+					t.Fatalf("unexpected error when deducing data types: %v", err)
+				}
+			}
+
+			_, report := deducer.Schema()
+			if len(report.Columns) != 1 {
+				t.Fatalf("expected 1 column report, got %d", len(report.Columns))
+			}
+			if !reflect.DeepEqual(report.Columns[0], testCase.want) {
+				t.Errorf("expected report %+v, got %+v", testCase.want, report.Columns[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestWidenDataType(t *testing.T) {
+	testCases := []struct {
+		dataType1 DataType
+		dataType2 DataType
+		want      DataType
+	}{
+		{DataTypeInt, DataTypeInt, DataTypeInt},
+		{DataTypeInt, DataTypeFloat, DataTypeFloat},
+		{DataTypeFloat, DataTypeInt, DataTypeFloat},
+		{DataTypeInt, DataTypeText, DataTypeText},
+		{DataTypeFloat, DataTypeBool, DataTypeText},
+		{DataTypeDate, DataTypeDateTime, DataTypeText},
+		{DataTypeUUID, DataTypeText, DataTypeText},
+	}
+
+	for _, testCase := range testCases {
+		widenedType := widenDataType(testCase.dataType1, testCase.dataType2)
+		if widenedType != testCase.want {
+			t.Errorf(
+				"expected %v and %v to widen to %v, got %v",
+				testCase.dataType1,
+				testCase.dataType2,
+				testCase.want,
+				widenedType,
+			)
+		}
+	}
+}
+
+func TestDeduceWidenedColumns(t *testing.T) {
+	testCases := []struct {
+		name          string
+		fields        []string
+		wantDataType  DataType
+		wantWidenings []TypeWidening
+	}{
+		{
+			name:         "No widening",
+			fields:       []string{"1", "", "2"},
+			wantDataType: DataTypeInt,
+		},
+		{
+			name:         "Integer to float",
+			fields:       []string{"1", "2", "2.5", "3"},
+			wantDataType: DataTypeFloat,
+			wantWidenings: []TypeWidening{
+				{
+					ColumnName: "value",
+					From:       DataTypeInt,
+					To:         DataTypeFloat,
+					RowNumber:  3,
+					Value:      "2.5",
+				},
+			},
+		},
+		{
+			name:         "Integer to text",
+			fields:       []string{"1", "2", "n/a"},
+			wantDataType: DataTypeText,
+			wantWidenings: []TypeWidening{
+				{
+					ColumnName: "value",
+					From:       DataTypeInt,
+					To:         DataTypeText,
+					RowNumber:  3,
+					Value:      "n/a",
+				},
+			},
+		},
+		{
+			name:         "Integer to float to text",
+			fields:       []string{"1", "2.5", "n/a", "3"},
+			wantDataType: DataTypeText,
+			wantWidenings: []TypeWidening{
+				{
+					ColumnName: "value",
+					From:       DataTypeFloat,
+					To:         DataTypeText,
+					RowNumber:  3,
+					Value:      "n/a",
+				},
+			},
+		},
+		{
+			name:         "Boolean to text",
+			fields:       []string{"true", "false", "maybe"},
+			wantDataType: DataTypeText,
+			wantWidenings: []TypeWidening{
+				{
+					ColumnName: "value",
+					From:       DataTypeBool,
+					To:         DataTypeText,
+					RowNumber:  3,
+					Value:      "maybe",
+				},
+			},
+		},
+		{
+			name:         "Different date formats",
+			fields:       []string{"2023-10-01", "25.12.2023"},
+			wantDataType: DataTypeText,
+			wantWidenings: []TypeWidening{
+				{
+					ColumnName: "value",
+					From:       DataTypeDate,
+					To:         DataTypeText,
+					RowNumber:  2,
+					Value:      "25.12.2023",
+				},
+			},
+		},
+		{
+			name:         "Geo points",
+			fields:       []string{"59.91,10.75", "60.39,5.32"},
+			wantDataType: DataTypeGeoPoint,
+		},
+		{
+			name:         "Geo points with blanks",
+			fields:       []string{"59.91,10.75", "", "60.39,5.32"},
+			wantDataType: DataTypeText,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			deducer := NewSchemaDeducer([]string{"value"}, ParseOptions{})
+			for i, field := range testCase.fields {
+				if err := deducer.DeduceDataTypesFromRow([]string{field}, i+1); err != nil {
+					t.Fatalf("unexpected error when deducing data types: %v", err)
+				}
+			}
+
+			schema, report := deducer.Schema()
+			if dataType := schema.Columns[0].DataType; dataType != testCase.wantDataType {
+				t.Errorf("expected data type %v, got %v", testCase.wantDataType, dataType)
+			}
+			if !slices.Equal(report.TypeWidenings, testCase.wantWidenings) {
+				t.Errorf(
+					"expected widenings %+v, got %+v",
+					testCase.wantWidenings,
+					report.TypeWidenings,
+				)
+			}
+		})
+	}
+}
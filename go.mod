@@ -8,6 +8,9 @@ require (
 	github.com/elastic/go-elasticsearch/v8 v8.10.0
 	github.com/google/uuid v1.3.1
 	github.com/joho/godotenv v1.5.1
+	github.com/klauspost/compress v1.16.7
+	github.com/paulmach/orb v0.10.0
+	github.com/shopspring/decimal v1.3.1
 	hermannm.dev/devlog v0.4.1
 	hermannm.dev/enumnames v0.2.1
 	hermannm.dev/wrap v0.3.1
@@ -19,13 +22,10 @@ require (
 	github.com/elastic/elastic-transport-go/v8 v8.0.0-20230329154755-1a3c63de0db6 // indirect
 	github.com/go-faster/city v1.0.1 // indirect
 	github.com/go-faster/errors v0.6.1 // indirect
-	github.com/klauspost/compress v1.16.7 // indirect
 	github.com/neilotoole/jsoncolor v0.7.1 // indirect
-	github.com/paulmach/orb v0.10.0 // indirect
 	github.com/pierrec/lz4/v4 v4.1.18 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/segmentio/asm v1.2.0 // indirect
-	github.com/shopspring/decimal v1.3.1 // indirect
 	go.opentelemetry.io/otel v1.17.0 // indirect
 	go.opentelemetry.io/otel/trace v1.17.0 // indirect
 	golang.org/x/sys v0.14.0 // indirect
@@ -0,0 +1,234 @@
+package jobs
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"hermannm.dev/analysis/db"
+	"hermannm.dev/devlog/log"
+)
+
+// Ingestion job running in the background, as returned to clients.
+type Job struct {
+	ID         string     `json:"id"`
+	Table      string     `json:"table"`
+	Status     JobStatus  `json:"status"`
+	Progress   Progress   `json:"progress"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	// Set once the job has succeeded. Failed jobs are rolled back, so they have no report.
+	Report *db.IngestionReport `json:"report,omitempty"`
+}
+
+type Progress struct {
+	RowsRead     int64 `json:"rowsRead"`
+	RowsInserted int64 `json:"rowsInserted"`
+	// Rows skipped in db.IngestionModeLenient.
+	RowsRejected int64 `json:"rowsRejected"`
+	// Position in the uploaded file. May go back if the file is read again from the start, such as
+	// after deducing its dialect.
+	BytesProcessed int64 `json:"bytesProcessed"`
+	// Size of the uploaded file, or 0 if unknown.
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+}
+
+// Tracks the progress of a job. Create one with NewTracker before starting the job, to count the
+// bytes read from its file with CountBytes.
+type Tracker struct {
+	ingestion      db.IngestionProgress
+	bytesProcessed atomic.Int64
+	totalBytes     int64
+}
+
+func NewTracker(totalBytes int64) *Tracker {
+	return &Tracker{totalBytes: totalBytes}
+}
+
+// Pass this in db.IngestionOptions.Progress to track rows read, inserted and rejected.
+func (tracker *Tracker) IngestionProgress() *db.IngestionProgress {
+	return &tracker.ingestion
+}
+
+// Returns a reader that updates the tracker's processed bytes as the given file is read.
+func (tracker *Tracker) CountBytes(file io.ReadSeeker) io.ReadSeeker {
+	return countingReader{file: file, tracker: tracker}
+}
+
+func (tracker *Tracker) progress() Progress {
+	return Progress{
+		RowsRead:       tracker.ingestion.RowsRead(),
+		RowsInserted:   tracker.ingestion.RowsInserted(),
+		RowsRejected:   tracker.ingestion.RowsRejected(),
+		BytesProcessed: tracker.bytesProcessed.Load(),
+		TotalBytes:     tracker.totalBytes,
+	}
+}
+
+type countingReader struct {
+	file    io.ReadSeeker
+	tracker *Tracker
+}
+
+func (reader countingReader) Read(buffer []byte) (int, error) {
+	n, err := reader.file.Read(buffer)
+	reader.tracker.bytesProcessed.Add(int64(n))
+	return n, err
+}
+
+func (reader countingReader) Seek(offset int64, whence int) (int64, error) {
+	position, err := reader.file.Seek(offset, whence)
+	if err == nil {
+		reader.tracker.bytesProcessed.Store(position)
+	}
+	return position, err
+}
+
+// Keeps track of ingestion jobs in memory, so jobs are lost on restart.
+type Manager struct {
+	jobs  map[string]*runningJob
+	mutex sync.Mutex
+}
+
+type runningJob struct {
+	// Guarded by Manager.mutex, except Progress, which is taken from tracker.
+	job     Job
+	tracker *Tracker
+	cancel  context.CancelFunc
+}
+
+// How long finished jobs are kept, so clients can get their results.
+const FinishedJobRetention = 24 * time.Hour
+
+func NewManager() *Manager {
+	return &Manager{jobs: make(map[string]*runningJob)}
+}
+
+// Runs the given ingestion function in a new goroutine, and returns the job tracking it. The
+// function's context is canceled when the job is canceled with Manager.Cancel. It is independent of
+// the given context otherwise, so that the job keeps running after the request that started it.
+func (manager *Manager) Start(
+	ctx context.Context,
+	table string,
+	tracker *Tracker,
+	run func(ctx context.Context) (db.IngestionReport, error),
+) Job {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	running := &runningJob{
+		job: Job{
+			ID:        uuid.NewString(),
+			Table:     table,
+			Status:    JobStatusRunning,
+			StartedAt: time.Now(),
+		},
+		tracker: tracker,
+		cancel:  cancel,
+	}
+
+	manager.mutex.Lock()
+	manager.removeExpiredJobs()
+	manager.jobs[running.job.ID] = running
+	job := running.snapshot()
+	manager.mutex.Unlock()
+
+	go func() {
+		defer cancel()
+
+		report, err := run(ctx)
+
+		manager.mutex.Lock()
+		defer manager.mutex.Unlock()
+
+		finishedAt := time.Now()
+		running.job.FinishedAt = &finishedAt
+
+		switch {
+		case err == nil:
+			running.job.Status = JobStatusSucceeded
+			running.job.Report = &report
+		case errors.Is(ctx.Err(), context.Canceled):
+			running.job.Status = JobStatusCanceled
+			running.job.Error = err.Error()
+		default:
+			running.job.Status = JobStatusFailed
+			running.job.Error = err.Error()
+			log.ErrorCause(err, "ingestion job failed", slog.String("jobId", running.job.ID))
+		}
+	}()
+
+	return job
+}
+
+// Returns all jobs, most recently started first.
+func (manager *Manager) List() []Job {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.removeExpiredJobs()
+
+	jobs := make([]Job, 0, len(manager.jobs))
+	for _, running := range manager.jobs {
+		jobs = append(jobs, running.snapshot())
+	}
+
+	slices.SortFunc(jobs, func(job1 Job, job2 Job) int {
+		return cmp.Compare(job2.StartedAt.UnixNano(), job1.StartedAt.UnixNano())
+	})
+	return jobs
+}
+
+func (manager *Manager) Get(id string) (job Job, ok bool) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	running, ok := manager.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return running.snapshot(), true
+}
+
+// Cancels the job with the given ID. The job's status is set to CANCELED once its ingestion
+// function has returned.
+func (manager *Manager) Cancel(id string) error {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	running, ok := manager.jobs[id]
+	if !ok {
+		return fmt.Errorf("no job found with ID '%s'", id)
+	}
+	if running.job.Status != JobStatusRunning {
+		return fmt.Errorf("job '%s' has already finished with status %v", id, running.job.Status)
+	}
+
+	running.cancel()
+	return nil
+}
+
+// Expects Manager.mutex to be locked.
+func (manager *Manager) removeExpiredJobs() {
+	for id, running := range manager.jobs {
+		if running.job.FinishedAt != nil &&
+			time.Since(*running.job.FinishedAt) > FinishedJobRetention {
+			delete(manager.jobs, id)
+		}
+	}
+}
+
+// Expects Manager.mutex to be locked.
+func (running *runningJob) snapshot() Job {
+	job := running.job
+	job.Progress = running.tracker.progress()
+	return job
+}
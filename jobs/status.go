@@ -0,0 +1,35 @@
+package jobs
+
+import "hermannm.dev/enumnames"
+
+type JobStatus int8
+
+const (
+	JobStatusRunning JobStatus = iota + 1
+	JobStatusSucceeded
+	JobStatusFailed
+	JobStatusCanceled
+)
+
+var jobStatusMap = enumnames.NewMap(map[JobStatus]string{
+	JobStatusRunning:   "RUNNING",
+	JobStatusSucceeded: "SUCCEEDED",
+	JobStatusFailed:    "FAILED",
+	JobStatusCanceled:  "CANCELED",
+})
+
+func (status JobStatus) IsValid() bool {
+	return jobStatusMap.ContainsKey(status)
+}
+
+func (status JobStatus) String() string {
+	return jobStatusMap.GetNameOrFallback(status, "INVALID_JOB_STATUS")
+}
+
+func (status JobStatus) MarshalJSON() ([]byte, error) {
+	return jobStatusMap.MarshalToNameJSON(status)
+}
+
+func (status *JobStatus) UnmarshalJSON(bytes []byte) error {
+	return jobStatusMap.UnmarshalFromNameJSON(bytes, status)
+}